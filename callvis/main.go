@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/wcatron/query-projects/internal/commands"
 	"github.com/wcatron/query-projects/internal/outputs"
@@ -10,10 +11,10 @@ import (
 
 func main() {
 	// Add all subcommands
-	commands.CMD_runScript("example.ts", []string{}, false, []string{}, []string{})
-	commands.CMD_addRepository("https://github.com/test/test")
-	commands.CMD_info()
-	commands.CMD_pullRepos([]string{})
+	commands.CMD_runScript("example.ts", []string{}, false, commands.RunOptions{}, []string{})
+	commands.CMD_addRepository("https://github.com/test/test", "", "")
+	commands.CMD_info(false)
+	commands.CMD_pullRepos([]string{}, "", "", false, false)
 	commands.CMD_syncRepos()
 	commands.CMD_ask("test question")
 
@@ -45,9 +46,10 @@ func main() {
 			Index:       0,
 		},
 	}
-	_ = outputs.WriteTable("", scriptInfo.Path, results)
-	_ = outputs.WriteCSVTable("", scriptInfo, results)
-	_ = outputs.WriteJSONOutput("", scriptInfo.Path, results)
+	base := outputs.ResultsBase("", "", scriptInfo.Path, nil, time.Now())
+	_ = outputs.WriteTable(os.Stdout, base, results)
+	_ = outputs.WriteCSVTable(os.Stdout, base, scriptInfo, results)
+	_ = outputs.WriteJSONOutput(os.Stdout, base, scriptInfo, results)
 
 	// Simulate project management
 	_, _ = projects.LoadProjects()
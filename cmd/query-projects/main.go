@@ -32,11 +32,22 @@ func Execute() {
 	rootCmd.AddCommand(commands.SyncCmd)
 	rootCmd.AddCommand(commands.PlanCmd)
 	rootCmd.AddCommand(commands.LoadCmd)
+	rootCmd.AddCommand(commands.ImportCmd)
+	rootCmd.AddCommand(commands.QueryCmd)
+	rootCmd.AddCommand(commands.TrendCmd)
+	rootCmd.AddCommand(commands.ExportCmd)
+	rootCmd.AddCommand(commands.ServeCmd)
 
 	// Add a flags for commands
+	commands.AskCmdInit(commands.AskCmd)
 	commands.RunCmdInit(commands.RunCmd)
 	commands.LoadCmdInit(commands.LoadCmd)
 	commands.PullCmdInit(commands.PullCmd)
+	commands.ImportCmdInit(commands.ImportCmd)
+	commands.QueryCmdInit(commands.QueryCmd)
+	commands.TrendCmdInit(commands.TrendCmd)
+	commands.ExportCmdInit(commands.ExportCmd)
+	commands.ServeCmdInit(commands.ServeCmd)
 
 	// Add flags for the root command
 	rootCmd.PersistentFlags().StringSliceP("topics", "t", nil, "Filter projects by topics")
@@ -44,7 +55,7 @@ func Execute() {
 
 	// Execute the root command
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println("Error:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+// DriftOptions describes a version drift report. Field holds the version and
+// is `output` or a csv column / json field. Key optionally splits the report,
+// e.g. by dependency name. Projects on a version below Target are behind.
+type DriftOptions struct {
+	Field  string
+	Key    string
+	Target string
+}
+
+// versionUse is one version found in a project.
+type versionUse struct {
+	label   string
+	key     string
+	raw     string
+	version Version
+	ok      bool
+}
+
+// Validate checks the target is a version before any script runs.
+func (opts DriftOptions) Validate() error {
+	if _, ok := ParseVersion(opts.Target); opts.Target != "" && !ok {
+		return fmt.Errorf("target %q isn't a version", opts.Target)
+	}
+	return nil
+}
+
+// Drift reads npm, Go module and Maven versions and ranges from a script's
+// results. Ranges such as ^18.2.0, >=1.4 <2, v1.2.3-0.2020... or [1.0,2.0) are
+// read as the version they start from. The first summary lists each major
+// version with its latest and oldest versions, the second every project behind
+// the target.
+func Drift(info outputs.ScriptInfo, results []outputs.Result, projectsList []projects.Project, opts DriftOptions) (outputs.Summary, outputs.Summary, error) {
+	if opts.Field == "" {
+		opts.Field = "output"
+	}
+	if err := opts.Validate(); err != nil {
+		return outputs.Summary{}, outputs.Summary{}, err
+	}
+	target, _ := ParseVersion(opts.Target)
+
+	var uses []versionUse
+	for _, r := range results {
+		project := projects.FindProject(projectsList, r.ProjectPath)
+		units := []unit{{result: r, project: project, rows: r.Rows}}
+		if !projectLevel(opts.Field) || (opts.Key != "" && !projectLevel(opts.Key)) {
+			units = nil
+			for _, row := range r.Rows {
+				units = append(units, unit{result: r, project: project, rows: []outputs.Row{row}})
+			}
+		}
+		for _, u := range units {
+			keyValues := []string{""}
+			if opts.Key != "" {
+				keyValues = keys(info, u, opts.Key)
+			}
+			for _, key := range keyValues {
+				for _, raw := range keys(info, u, opts.Field) {
+					if raw == noValue {
+						continue
+					}
+					version, ok := ParseVersion(raw)
+					uses = append(uses, versionUse{label: r.Label(), key: key, raw: raw, version: version, ok: ok})
+				}
+			}
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(info.Path), ".ts")
+	overview := driftOverview(uses, opts)
+	overview.Title = fmt.Sprintf("%s %s drift", name, opts.Field)
+	behind := outputs.Summary{
+		Title:   fmt.Sprintf("%s behind %s", name, opts.Target),
+		Columns: []string{"Project", opts.Field, "target"},
+	}
+	if opts.Key != "" {
+		behind.Columns = []string{"Project", opts.Key, opts.Field, "target"}
+	}
+	if opts.Target == "" {
+		return overview, behind, nil
+	}
+
+	sort.SliceStable(uses, func(i, j int) bool { return uses[i].version.Compare(uses[j].version) < 0 })
+	for _, use := range uses {
+		if !use.ok || use.version.Compare(target) >= 0 {
+			continue
+		}
+		row := outputs.Row{use.label}
+		if opts.Key != "" {
+			row = append(row, use.key)
+		}
+		behind.Rows = append(behind.Rows, append(row, use.raw, opts.Target))
+	}
+	return overview, behind, nil
+}
+
+// driftOverview groups the versions by key and major version. Values that
+// aren't versions, such as latest or workspace:*, are grouped as unknown.
+func driftOverview(uses []versionUse, opts DriftOptions) outputs.Summary {
+	type majorGroup struct {
+		key, major     string
+		count          int
+		latest, oldest *versionUse
+	}
+	groups := map[[2]string]*majorGroup{}
+	totals := map[string]int{}
+	var order [][2]string
+	for i, use := range uses {
+		major := "unknown"
+		if use.ok {
+			major = strconv.Itoa(use.version.Major)
+		}
+		id := [2]string{use.key, major}
+		g, found := groups[id]
+		if !found {
+			g = &majorGroup{key: use.key, major: major}
+			groups[id] = g
+			order = append(order, id)
+		}
+		g.count++
+		totals[use.key]++
+		if use.ok && (g.latest == nil || use.version.Compare(g.latest.version) > 0) {
+			g.latest = &uses[i]
+		}
+		if use.ok && (g.oldest == nil || use.version.Compare(g.oldest.version) < 0) {
+			g.oldest = &uses[i]
+		}
+	}
+
+	// Newest major first within each key, unknown versions last
+	sort.Slice(order, func(i, j int) bool {
+		if order[i][0] != order[j][0] {
+			return order[i][0] < order[j][0]
+		}
+		mi, errI := strconv.Atoi(order[i][1])
+		mj, errJ := strconv.Atoi(order[j][1])
+		if errI != nil || errJ != nil {
+			return errJ != nil && errI == nil
+		}
+		return mi > mj
+	})
+
+	summary := outputs.Summary{Columns: []string{"major", "count", "percentage", "latest", "oldest"}}
+	if opts.Key != "" {
+		summary.Columns = append([]string{opts.Key}, summary.Columns...)
+	}
+	for _, id := range order {
+		g := groups[id]
+		var latest, oldest any
+		if g.latest != nil {
+			latest, oldest = g.latest.raw, g.oldest.raw
+		}
+		row := outputs.Row{g.major, g.count, math.Round(float64(g.count)/float64(totals[g.key])*1000) / 10, latest, oldest}
+		if opts.Key != "" {
+			row = append(outputs.Row{g.key}, row...)
+		}
+		summary.Rows = append(summary.Rows, row)
+	}
+	return summary
+}
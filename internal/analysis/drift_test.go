@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestDrift(t *testing.T) {
+	info := outputs.ScriptInfo{Path: "scripts/node.ts", Output: "text"}
+	results := []outputs.Result{
+		{ProjectPath: "projects/a", Status: "Success", Rows: []outputs.Row{{"v18.19.0"}}},
+		{ProjectPath: "projects/b", Status: "Success", Rows: []outputs.Row{{">=20.1 <21"}}},
+		{ProjectPath: "projects/c", Status: "Success", Rows: []outputs.Row{{"^18.2.0"}}},
+		{ProjectPath: "projects/d", Status: "Success", Rows: []outputs.Row{{"latest"}}},
+		{ProjectPath: "projects/e", Status: "Success"},
+	}
+
+	overview, behind, err := Drift(info, results, nil, DriftOptions{Target: "20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []outputs.Row{
+		{"20", 1, 25.0, ">=20.1 <21", ">=20.1 <21"},
+		{"18", 2, 50.0, "v18.19.0", "^18.2.0"},
+		{"unknown", 1, 25.0, nil, nil},
+	}
+	if !reflect.DeepEqual(overview.Rows, want) {
+		t.Errorf("Expected overview %v, got %v", want, overview.Rows)
+	}
+	wantBehind := []outputs.Row{
+		{"projects/c", "^18.2.0", "20"},
+		{"projects/a", "v18.19.0", "20"},
+	}
+	if !reflect.DeepEqual(behind.Rows, wantBehind) {
+		t.Errorf("Expected behind %v, got %v", wantBehind, behind.Rows)
+	}
+
+	if _, _, err := Drift(info, results, nil, DriftOptions{Target: "next"}); err == nil {
+		t.Errorf("Expected an invalid target to fail")
+	}
+}
+
+func TestDriftByKey(t *testing.T) {
+	info := outputs.ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	results := []outputs.Result{
+		{ProjectPath: "projects/api", Status: "Success", Rows: []outputs.Row{{"github.com/pkg/errors", "v0.9.1"}, {"junit:junit", "[4.12,5.0)"}}},
+		{ProjectPath: "projects/web", Status: "Success", Rows: []outputs.Row{{"junit:junit", "4.13.2"}}},
+	}
+
+	overview, behind, err := Drift(info, results, nil, DriftOptions{Field: "version", Key: "name", Target: "4.13"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []outputs.Row{
+		{"github.com/pkg/errors", "0", 1, 100.0, "v0.9.1", "v0.9.1"},
+		{"junit:junit", "4", 2, 100.0, "4.13.2", "[4.12,5.0)"},
+	}
+	if !reflect.DeepEqual(overview.Rows, want) {
+		t.Errorf("Expected overview %v, got %v", want, overview.Rows)
+	}
+	if len(behind.Rows) != 2 || behind.Rows[1][1] != "junit:junit" {
+		t.Errorf("Unexpected behind rows %v", behind.Rows)
+	}
+}
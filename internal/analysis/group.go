@@ -0,0 +1,279 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+// DefaultAggregates are used when no aggregates are given.
+var DefaultAggregates = []string{"count", "percentage"}
+
+const noValue = "(none)"
+
+// GroupOptions describes a grouped report. GroupBy and Pivot are `output`,
+// `status`, `topic`, `metadata.<field>`, or a csv column / json field.
+// Aggregates are `count`, `percentage`, or `distinct`, `min`, `max` and
+// `semver-max` followed by `:<column>`.
+type GroupOptions struct {
+	GroupBy    string
+	Pivot      string
+	Aggregates []string
+}
+
+type aggregate struct {
+	fn     string
+	column string
+}
+
+func (a aggregate) label() string {
+	if a.column == "" {
+		return a.fn
+	}
+	return fmt.Sprintf("%s(%s)", a.fn, a.column)
+}
+
+func parseAggregates(raw []string) ([]aggregate, error) {
+	if len(raw) == 0 {
+		raw = DefaultAggregates
+	}
+	var aggregates []aggregate
+	for _, r := range raw {
+		fn, column, _ := strings.Cut(strings.TrimSpace(r), ":")
+		switch fn {
+		case "count", "percentage":
+			if column != "" {
+				return nil, fmt.Errorf("aggregate %s doesn't take a column", fn)
+			}
+		case "distinct", "min", "max", "semver-max":
+			if column == "" {
+				return nil, fmt.Errorf("aggregate %s needs a column, e.g. %s:version", fn, fn)
+			}
+		default:
+			return nil, fmt.Errorf("unknown aggregate %q", fn)
+		}
+		aggregates = append(aggregates, aggregate{fn: fn, column: column})
+	}
+	return aggregates, nil
+}
+
+// unit is what gets counted: a row when grouping by a column, otherwise a
+// project with all of its rows.
+type unit struct {
+	result  outputs.Result
+	project *projects.Project
+	rows    []outputs.Row
+}
+
+// projectLevel reports whether a dimension is a property of the whole project
+// rather than of each row.
+func projectLevel(dimension string) bool {
+	return dimension == "" || dimension == "output" || dimension == "status" || dimension == "topic" || dimension == "topics" || strings.HasPrefix(dimension, "metadata.")
+}
+
+// keys returns the groups a unit belongs to for a dimension. Projects belong to
+// one group per topic.
+func keys(info outputs.ScriptInfo, u unit, dimension string) []string {
+	var values []string
+	switch {
+	case dimension == "output":
+		values = []string{strings.TrimSpace(u.result.OutputText())}
+	case dimension == "status":
+		values = []string{u.result.Status}
+	case dimension == "topic" || dimension == "topics":
+		if u.project != nil {
+			values = u.project.Topics
+		}
+	case strings.HasPrefix(dimension, "metadata."):
+		if u.project != nil {
+			values = []string{u.project.MetadataValue(strings.TrimPrefix(dimension, "metadata."))}
+		}
+	default:
+		for _, row := range u.rows {
+			if value, ok := outputs.FieldValue(info, row, dimension); ok && !slices.Contains(values, value) {
+				values = append(values, value)
+			}
+		}
+	}
+
+	values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == "" })
+	if len(values) == 0 {
+		return []string{noValue}
+	}
+	return values
+}
+
+// columnValues collects a column's value from every row of the units.
+func columnValues(info outputs.ScriptInfo, units []unit, column string) []string {
+	var values []string
+	for _, u := range units {
+		for _, row := range u.rows {
+			if value, ok := outputs.FieldValue(info, row, column); ok && value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// extreme returns the smallest or largest value, comparing numerically when
+// every value is a number.
+func extreme(values []string, largest bool) any {
+	if len(values) == 0 {
+		return nil
+	}
+	numbers := make([]float64, len(values))
+	numeric := true
+	for i, v := range values {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			numeric = false
+			break
+		}
+		numbers[i] = n
+	}
+	if numeric {
+		if largest {
+			return slices.Max(numbers)
+		}
+		return slices.Min(numbers)
+	}
+	if largest {
+		return slices.Max(values)
+	}
+	return slices.Min(values)
+}
+
+// semverMax returns the highest value that parses as a version, as written.
+func semverMax(values []string) any {
+	var best string
+	var bestVersion Version
+	for _, v := range values {
+		version, ok := ParseVersion(v)
+		if ok && (best == "" || version.Compare(bestVersion) > 0) {
+			best, bestVersion = v, version
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return best
+}
+
+func (a aggregate) compute(info outputs.ScriptInfo, units []unit, total int) any {
+	switch a.fn {
+	case "count":
+		return len(units)
+	case "percentage":
+		if total == 0 {
+			return 0.0
+		}
+		return math.Round(float64(len(units))/float64(total)*1000) / 10
+	case "distinct":
+		values := columnValues(info, units, a.column)
+		slices.Sort(values)
+		return len(slices.Compact(values))
+	case "min":
+		return extreme(columnValues(info, units, a.column), false)
+	case "max":
+		return extreme(columnValues(info, units, a.column), true)
+	case "semver-max":
+		return semverMax(columnValues(info, units, a.column))
+	}
+	return nil
+}
+
+// Validate checks the options before any script runs, so a typo doesn't cost
+// a whole run.
+func (opts GroupOptions) Validate() error {
+	if opts.GroupBy == "" {
+		return fmt.Errorf("nothing to group by")
+	}
+	_, err := parseAggregates(opts.Aggregates)
+	return err
+}
+
+// Group groups a script's results and computes aggregates for every group.
+// With a pivot, each distinct pivot value becomes a column counting the group's
+// units with that value. Groups are ordered by count, largest first.
+func Group(info outputs.ScriptInfo, results []outputs.Result, projectsList []projects.Project, opts GroupOptions) (outputs.Summary, error) {
+	if err := opts.Validate(); err != nil {
+		return outputs.Summary{}, err
+	}
+	aggregates, _ := parseAggregates(opts.Aggregates)
+
+	rowLevel := !projectLevel(opts.GroupBy) || !projectLevel(opts.Pivot)
+	var units []unit
+	for _, r := range results {
+		project := projects.FindProject(projectsList, r.ProjectPath)
+		if !rowLevel {
+			units = append(units, unit{result: r, project: project, rows: r.Rows})
+			continue
+		}
+		for _, row := range r.Rows {
+			units = append(units, unit{result: r, project: project, rows: []outputs.Row{row}})
+		}
+	}
+
+	groups := map[string][]unit{}
+	pivotValues := map[string]bool{}
+	for _, u := range units {
+		for _, key := range keys(info, u, opts.GroupBy) {
+			groups[key] = append(groups[key], u)
+		}
+		if opts.Pivot != "" {
+			for _, value := range keys(info, u, opts.Pivot) {
+				pivotValues[value] = true
+			}
+		}
+	}
+
+	groupKeys := make([]string, 0, len(groups))
+	for key := range groups {
+		groupKeys = append(groupKeys, key)
+	}
+	sort.Slice(groupKeys, func(i, j int) bool {
+		if len(groups[groupKeys[i]]) != len(groups[groupKeys[j]]) {
+			return len(groups[groupKeys[i]]) > len(groups[groupKeys[j]])
+		}
+		return groupKeys[i] < groupKeys[j]
+	})
+	pivotColumns := make([]string, 0, len(pivotValues))
+	for value := range pivotValues {
+		pivotColumns = append(pivotColumns, value)
+	}
+	sort.Strings(pivotColumns)
+
+	summary := outputs.Summary{
+		Title:   fmt.Sprintf("%s by %s", strings.TrimSuffix(filepath.Base(info.Path), ".ts"), opts.GroupBy),
+		Columns: append([]string{opts.GroupBy}, pivotColumns...),
+	}
+	for _, a := range aggregates {
+		summary.Columns = append(summary.Columns, a.label())
+	}
+
+	for _, key := range groupKeys {
+		row := outputs.Row{key}
+		for _, value := range pivotColumns {
+			count := 0
+			for _, u := range groups[key] {
+				if slices.Contains(keys(info, u, opts.Pivot), value) {
+					count++
+				}
+			}
+			row = append(row, count)
+		}
+		for _, a := range aggregates {
+			row = append(row, a.compute(info, groups[key], len(units)))
+		}
+		summary.Rows = append(summary.Rows, row)
+	}
+	return summary, nil
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+var (
+	depsInfo    = outputs.ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	depsResults = []outputs.Result{
+		{ProjectPath: "projects/a", Status: "Success", Rows: []outputs.Row{{"react", "^18.2.0"}, {"lodash", "4.17.21"}}},
+		{ProjectPath: "projects/b", Status: "Success", Rows: []outputs.Row{{"react", "17.0.2"}}},
+		{ProjectPath: "projects/c", Status: "Failed (exit code 1)"},
+	}
+	depsProjects = []projects.Project{
+		{Path: "projects/a", Topics: []string{"web", "frontend"}, Metadata: map[string]any{"language": "TypeScript"}},
+		{Path: "projects/b", Topics: []string{"web"}, Metadata: map[string]any{"language": "JavaScript"}},
+		{Path: "projects/c", Metadata: map[string]any{"language": "TypeScript"}},
+	}
+)
+
+func TestGroupByColumn(t *testing.T) {
+	summary, err := Group(depsInfo, depsResults, depsProjects, GroupOptions{
+		GroupBy:    "name",
+		Aggregates: []string{"count", "percentage", "distinct:version", "semver-max:version"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantColumns := []string{"name", "count", "percentage", "distinct(version)", "semver-max(version)"}
+	if !reflect.DeepEqual(summary.Columns, wantColumns) {
+		t.Errorf("Expected columns %v, got %v", wantColumns, summary.Columns)
+	}
+	want := []outputs.Row{
+		{"react", 2, 66.7, 2, "^18.2.0"},
+		{"lodash", 1, 33.3, 1, "4.17.21"},
+	}
+	if !reflect.DeepEqual(summary.Rows, want) {
+		t.Errorf("Expected rows %v, got %v", want, summary.Rows)
+	}
+}
+
+func TestGroupByProject(t *testing.T) {
+	summary, err := Group(depsInfo, depsResults, depsProjects, GroupOptions{GroupBy: "metadata.language", Pivot: "status", Aggregates: []string{"count"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []outputs.Row{
+		{"TypeScript", 1, 1, 2},
+		{"JavaScript", 0, 1, 1},
+	}
+	if !reflect.DeepEqual(summary.Columns, []string{"metadata.language", "Failed (exit code 1)", "Success", "count"}) || !reflect.DeepEqual(summary.Rows, want) {
+		t.Errorf("Unexpected pivot %v %v", summary.Columns, summary.Rows)
+	}
+
+	summary, err = Group(depsInfo, depsResults, depsProjects, GroupOptions{GroupBy: "topic", Aggregates: []string{"count", "max:version"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []outputs.Row{
+		{"web", 2, "^18.2.0"},
+		{"(none)", 1, nil},
+		{"frontend", 1, "^18.2.0"},
+	}
+	if !reflect.DeepEqual(summary.Rows, want) {
+		t.Errorf("Expected rows %v, got %v", want, summary.Rows)
+	}
+}
+
+func TestGroupInvalidAggregate(t *testing.T) {
+	for _, aggregate := range []string{"median", "max", "count:name"} {
+		if _, err := Group(depsInfo, depsResults, depsProjects, GroupOptions{GroupBy: "name", Aggregates: []string{aggregate}}); err == nil {
+			t.Errorf("Expected %q to be rejected", aggregate)
+		}
+		if err := (GroupOptions{GroupBy: "name", Aggregates: []string{aggregate}}).Validate(); err == nil {
+			t.Errorf("Expected %q to be rejected before running", aggregate)
+		}
+	}
+}
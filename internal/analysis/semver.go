@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Missing minor and patch numbers are zero.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?`)
+
+// upperBound matches the end of the text before a version that makes it an
+// upper bound, as in <2, <= 1.4 or the Maven range (,1.0].
+var upperBound = regexp.MustCompile(`(<=?|[(\[]\s*,)\s*$`)
+
+// ParseVersion reads the first version in s that isn't an upper bound, so
+// ranges and prefixes such as ^18.2.0, ~1.4, >=2 <3 and v1.2.3 give the version
+// they start from. Ranges with only an upper bound, such as <2 or (,1.0], have
+// no version.
+func ParseVersion(s string) (Version, bool) {
+	for _, loc := range versionPattern.FindAllStringSubmatchIndex(s, -1) {
+		if upperBound.MatchString(s[:loc[0]]) {
+			continue
+		}
+		group := func(i int) string {
+			if loc[2*i] < 0 {
+				return ""
+			}
+			return s[loc[2*i]:loc[2*i+1]]
+		}
+		var v Version
+		v.Major, _ = strconv.Atoi(group(1))
+		v.Minor, _ = strconv.Atoi(group(2))
+		v.Patch, _ = strconv.Atoi(group(3))
+		v.Prerelease = group(4)
+		return v, true
+	}
+	return Version{}, false
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1. A prerelease sorts before its release.
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares dot separated identifiers, numerically when both
+// are numbers.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
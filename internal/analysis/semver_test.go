@@ -0,0 +1,56 @@
+package analysis
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"^18.2.0", "17.0.2", 1},
+		{"v1.10", "1.9.9", 1},
+		{"~2", "2.0.0", 0},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+	}
+	for _, tt := range tests {
+		a, okA := ParseVersion(tt.a)
+		b, okB := ParseVersion(tt.b)
+		if !okA || !okB {
+			t.Fatalf("Failed to parse %q or %q", tt.a, tt.b)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if _, ok := ParseVersion("latest"); ok {
+		t.Errorf("Expected latest not to parse")
+	}
+}
+
+func TestParseVersion_Ranges(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{">=1.2 <2", "1.2.0"},
+		{"<2 >=1.4", "1.4.0"},
+		{"[1.0,2.0)", "1.0.0"},
+		{"<2", ""},
+		{"<= 1.4.0", ""},
+		{"(,1.0]", ""},
+		{"[ , 3)", ""},
+	}
+	for _, tt := range tests {
+		v, ok := ParseVersion(tt.s)
+		got := ""
+		if ok {
+			got = v.String()
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %q, expected %q", tt.s, got, tt.want)
+		}
+	}
+}
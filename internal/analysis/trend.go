@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+// TrendRun is one run of a script with the values of a column for each
+// project it ran against. Projects without a value have an empty slice.
+type TrendRun struct {
+	ID      int64
+	Started time.Time
+	Args    []string
+	Values  map[string][]string
+}
+
+// Label names the run in report columns.
+func (r TrendRun) Label() string {
+	return fmt.Sprintf("#%d %s", r.ID, r.Started.Local().Format("2006-01-02"))
+}
+
+// TrendOptions describes a trend report. Bucket groups versions by major or
+// minor version, so 18.2.0 and 18.3.1 both count as 18.x.
+type TrendOptions struct {
+	Bucket string
+}
+
+// TrendReport compares each run of a script with the one before it. Counts has
+// a row per value with the number of projects that had it in each run, the
+// change since the previous run and a sparkline. Runs lists how many projects
+// each run saw and how many moved to other values, were new or were gone.
+// Moves lists the projects that changed in the latest run.
+type TrendReport struct {
+	Counts outputs.Summary
+	Runs   outputs.Summary
+	Moves  outputs.Summary
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws counts as a row of bars scaled to the largest.
+func Sparkline(counts []int) string {
+	largest := slices.Max(append([]int{0}, counts...))
+	var sb strings.Builder
+	for _, c := range counts {
+		i := 0
+		if largest > 0 {
+			i = c * (len(sparks) - 1) / largest
+		}
+		sb.WriteRune(sparks[i])
+	}
+	return sb.String()
+}
+
+// bucket groups a value by its major or minor version. Values that aren't
+// versions are kept as is.
+func bucket(value string, mode string) string {
+	v, ok := ParseVersion(value)
+	if !ok {
+		return value
+	}
+	switch mode {
+	case "major":
+		return fmt.Sprintf("%d.x", v.Major)
+	case "minor":
+		return fmt.Sprintf("%d.%d.x", v.Major, v.Minor)
+	default:
+		return value
+	}
+}
+
+// bucketedValues returns the distinct bucketed values of a project, sorted.
+func bucketedValues(values []string, mode string) []string {
+	var out []string
+	for _, value := range values {
+		if b := bucket(value, mode); !slices.Contains(out, b) {
+			out = append(out, b)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Trend builds a trend report from runs, oldest first.
+func Trend(runs []TrendRun, opts TrendOptions) (TrendReport, error) {
+	if opts.Bucket != "" && opts.Bucket != "major" && opts.Bucket != "minor" {
+		return TrendReport{}, fmt.Errorf("unsupported bucket %q, expected major or minor", opts.Bucket)
+	}
+	if len(runs) == 0 {
+		return TrendReport{}, fmt.Errorf("no runs to compare")
+	}
+
+	// Bucket every project's values up front
+	values := make([]map[string][]string, len(runs))
+	counts := map[string][]int{}
+	for i, run := range runs {
+		values[i] = map[string][]string{}
+		for project, raw := range run.Values {
+			values[i][project] = bucketedValues(raw, opts.Bucket)
+			for _, value := range values[i][project] {
+				if counts[value] == nil {
+					counts[value] = make([]int, len(runs))
+				}
+				counts[value][i]++
+			}
+		}
+	}
+
+	var report TrendReport
+	last := len(runs) - 1
+
+	report.Counts = outputs.Summary{Title: "Projects per value", Columns: []string{"Value"}}
+	for _, run := range runs {
+		report.Counts.Columns = append(report.Counts.Columns, run.Label())
+	}
+	report.Counts.Columns = append(report.Counts.Columns, "Change", "Trend")
+	sortedValues := slices.Collect(maps.Keys(counts))
+	sort.Slice(sortedValues, func(i, j int) bool {
+		a, b := counts[sortedValues[i]][last], counts[sortedValues[j]][last]
+		if a != b {
+			return a > b
+		}
+		return compareValues(sortedValues[i], sortedValues[j]) < 0
+	})
+	for _, value := range sortedValues {
+		row := outputs.Row{value}
+		for _, c := range counts[value] {
+			row = append(row, c)
+		}
+		change := 0
+		if last > 0 {
+			change = counts[value][last] - counts[value][last-1]
+		}
+		row = append(row, fmt.Sprintf("%+d", change), Sparkline(counts[value]))
+		report.Counts.Rows = append(report.Counts.Rows, row)
+	}
+
+	report.Runs = outputs.Summary{Title: "Runs", Columns: []string{"Run", "Started", "Projects", "Moved", "New", "Gone"}}
+	for i, run := range runs {
+		row := outputs.Row{run.ID, run.Started.Local().Format("2006-01-02 15:04"), len(values[i])}
+		if i == 0 {
+			row = append(row, "", "", "")
+		} else {
+			moved, added, gone := compareRuns(values[i-1], values[i])
+			row = append(row, len(moved), len(added), len(gone))
+		}
+		report.Runs.Rows = append(report.Runs.Rows, row)
+	}
+
+	report.Moves = outputs.Summary{Title: "Changes in " + runs[last].Label(), Columns: []string{"Project", "From", "To"}}
+	if last > 0 {
+		before, after := values[last-1], values[last]
+		moved, added, gone := compareRuns(before, after)
+		for _, project := range moved {
+			report.Moves.Rows = append(report.Moves.Rows, outputs.Row{project, strings.Join(before[project], ", "), strings.Join(after[project], ", ")})
+		}
+		for _, project := range added {
+			report.Moves.Rows = append(report.Moves.Rows, outputs.Row{project, "(new)", strings.Join(after[project], ", ")})
+		}
+		for _, project := range gone {
+			report.Moves.Rows = append(report.Moves.Rows, outputs.Row{project, strings.Join(before[project], ", "), "(gone)"})
+		}
+	}
+	return report, nil
+}
+
+// compareRuns lists the projects whose values changed, that are only in after
+// and that are only in before, each sorted.
+func compareRuns(before, after map[string][]string) (moved, added, gone []string) {
+	for _, project := range slices.Sorted(maps.Keys(after)) {
+		previous, ok := before[project]
+		switch {
+		case !ok:
+			added = append(added, project)
+		case !slices.Equal(previous, after[project]):
+			moved = append(moved, project)
+		}
+	}
+	for _, project := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[project]; !ok {
+			gone = append(gone, project)
+		}
+	}
+	return moved, added, gone
+}
+
+// compareValues orders versions by version and everything else as text.
+func compareValues(a, b string) int {
+	va, okA := ParseVersion(a)
+	vb, okB := ParseVersion(b)
+	if okA && okB {
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
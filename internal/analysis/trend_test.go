@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestTrend(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.Local)
+	runs := []TrendRun{
+		{ID: 1, Started: start, Values: map[string][]string{
+			"projects/a": {"4.9.5"}, "projects/b": {"4.2.0"}, "projects/c": {"5.0.2"},
+		}},
+		{ID: 2, Started: start.Add(week), Values: map[string][]string{
+			"projects/a": {"5.1.0"}, "projects/b": {"4.2.0"}, "projects/c": {"5.0.2"}, "projects/d": {},
+		}},
+		{ID: 3, Started: start.Add(2 * week), Values: map[string][]string{
+			"projects/a": {"5.1.0"}, "projects/b": {"5.4.0"}, "projects/d": {"5.4.0"},
+		}},
+	}
+
+	report, err := Trend(runs, TrendOptions{Bucket: "major"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantColumns := []string{"Value", "#1 2026-09-01", "#2 2026-09-08", "#3 2026-09-15", "Change", "Trend"}
+	if !reflect.DeepEqual(report.Counts.Columns, wantColumns) {
+		t.Errorf("Expected columns %v, got %v", wantColumns, report.Counts.Columns)
+	}
+	wantCounts := []outputs.Row{
+		{"5.x", 1, 2, 3, "+1", "▃▅█"},
+		{"4.x", 2, 1, 0, "-1", "█▄▁"},
+	}
+	if !reflect.DeepEqual(report.Counts.Rows, wantCounts) {
+		t.Errorf("Expected counts %v, got %v", wantCounts, report.Counts.Rows)
+	}
+
+	wantRuns := []outputs.Row{
+		{int64(1), "2026-09-01 12:00", 3, "", "", ""},
+		{int64(2), "2026-09-08 12:00", 4, 1, 1, 0},
+		{int64(3), "2026-09-15 12:00", 3, 2, 0, 1},
+	}
+	if !reflect.DeepEqual(report.Runs.Rows, wantRuns) {
+		t.Errorf("Expected runs %v, got %v", wantRuns, report.Runs.Rows)
+	}
+
+	wantMoves := []outputs.Row{
+		{"projects/b", "4.x", "5.x"},
+		{"projects/d", "", "5.x"},
+		{"projects/c", "5.x", "(gone)"},
+	}
+	if !reflect.DeepEqual(report.Moves.Rows, wantMoves) {
+		t.Errorf("Expected moves %v, got %v", wantMoves, report.Moves.Rows)
+	}
+
+	if _, err := Trend(runs, TrendOptions{Bucket: "patch"}); err == nil {
+		t.Error("Expected an error for an unsupported bucket")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]int{0, 0}); got != "▁▁" {
+		t.Errorf("Expected ▁▁, got %s", got)
+	}
+	if got := Sparkline([]int{1, 4, 7}); got != "▂▅█" {
+		t.Errorf("Expected ▂▅█, got %s", got)
+	}
+}
@@ -3,8 +3,6 @@ package commands
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wcatron/query-projects/internal/projects"
@@ -37,27 +35,19 @@ func CMD_addRepository(repoURL string, token string, user string) error {
 	}
 
 	// Derive a project name from the repo URL
-	projectName := strings.TrimSuffix(filepath.Base(repoURL), ".git")
-	if projectName == "" {
-		projectName = "unknown-project"
-	}
-	projectPath := filepath.Join("projects", projectName)
+	project := projects.ProjectFromURL(repoURL)
 
 	flags := make(map[string]string)
-	if err := projects.CloneRepository(repoURL, projectPath, token, user, true, flags); err != nil {
+	if err := projects.CloneRepository(repoURL, project.Path, token, user, true, flags); err != nil {
 		return err
 	}
 
 	// Add to our in-memory list of projects
-	projectsList.Projects = append(projectsList.Projects, projects.Project{
-		Name:    projectName,
-		Path:    projectPath,
-		RepoURL: repoURL,
-	})
+	projectsList.Projects = append(projectsList.Projects, project)
 	if err := projects.SaveProjects(projectsList); err != nil {
 		return err
 	}
 
-	fmt.Printf("Added %s to %s.\n", projectName, projects.ProjectsFile)
+	fmt.Printf("Added %s to %s.\n", project.Name, projects.ProjectsFile)
 	return nil
 }
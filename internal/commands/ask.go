@@ -11,11 +11,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wcatron/query-projects/internal/projects"
 	"github.com/wcatron/query-projects/internal/scripts"
+	"github.com/wcatron/query-projects/internal/store"
 )
 
 var AskCmd = &cobra.Command{
@@ -27,10 +29,19 @@ var AskCmd = &cobra.Command{
 			return errors.New("please provide a question after 'query'")
 		}
 		question := strings.Join(args, " ")
+		if askSQL, _ := cmd.Flags().GetBool("sql"); askSQL {
+			dbPath, _ := cmd.Flags().GetString("db")
+			return CMD_askSQL(question, dbPath)
+		}
 		return CMD_ask(question)
 	},
 }
 
+func AskCmdInit(cmd *cobra.Command) {
+	cmd.Flags().Bool("sql", false, "Answer the question with SQL over the results database instead of generating a script")
+	cmd.Flags().StringP("db", "d", store.DefaultPath, "Path to SQLite database file for --sql")
+}
+
 // CMD_ask calls the OpenAI API with the question, extracts the TypeScript code,
 // and saves it to ./scripts/<question>.ts.
 func CMD_ask(question string) error {
@@ -46,21 +57,26 @@ func CMD_ask(question string) error {
 		return fmt.Errorf("failed to load projects: %w", err)
 	}
 
-	randomIndex := rand.Intn(len(projectsList.Projects))
-	randomProject := projectsList.Projects[randomIndex]
-
 	cwd, _ := os.Getwd()
-	fmt.Printf("Running script for project: %s\n", randomProject.Name)
-	scriptInfo, err := getScriptInfo(filepath.Join(cwd, projects.ScriptsFolder, scriptName), *projectsList)
+	scriptInfo, err := getScriptInfo(os.Stdout, filepath.Join(cwd, projects.ScriptsFolder, scriptName), *projectsList)
 	if err != nil {
 		return fmt.Errorf("failed to get script info: %w", err)
 	}
-	result, err := scripts.RunScriptForProject(projectsList, scriptInfo, randomProject.Path, []string{}, true)
+	candidates := slices.DeleteFunc(slices.Clone(projectsList.Projects), func(project projects.Project) bool {
+		return projectsList.RunConfigFor(project, scriptInfo.Path).Excluded
+	})
+	if len(candidates) == 0 {
+		return fmt.Errorf("%s is excluded for every project by its run configuration", scriptInfo.Path)
+	}
+
+	randomProject := candidates[rand.Intn(len(candidates))]
+	fmt.Printf("Running script for project: %s\n", randomProject.Name)
+	result, err := scripts.RunScriptForProject(projectsList, scriptInfo, randomProject, []string{}, os.Stdout)
 	if err != nil {
 		return fmt.Errorf("error running script: %w", err)
 	}
 
-	fmt.Printf("Result:\n%s\n", result.StdoutText)
+	fmt.Printf("Result:\n%s\n", result.OutputText())
 
 	// Prompt user for feedback
 	reader := bufio.NewReader(os.Stdin)
@@ -84,26 +100,107 @@ func CMD_ask(question string) error {
 			}
 		}
 		// Run for another random project
-		randomIndex = rand.Intn(len(projectsList.Projects))
-		randomProject = projectsList.Projects[randomIndex]
+		randomProject = candidates[rand.Intn(len(candidates))]
 		fmt.Printf("Running next script for project: %s\n", randomProject.Name)
-		scriptInfo, err = getScriptInfo(filepath.Join(cwd, projects.ScriptsFolder, scriptName), *projectsList)
+		scriptInfo, err = getScriptInfo(os.Stdout, filepath.Join(cwd, projects.ScriptsFolder, scriptName), *projectsList)
 		if err != nil {
 			fmt.Printf("Failed to get script info: %v\n", err)
 			continue
 		}
-		result, err = scripts.RunScriptForProject(projectsList, scriptInfo, randomProject.Path, []string{}, true)
+		result, err = scripts.RunScriptForProject(projectsList, scriptInfo, randomProject, []string{}, os.Stdout)
 		if err != nil {
 			fmt.Printf("Error running script: %v\n", err)
 		} else {
-			fmt.Printf("Result:\n%s\n", result.StdoutText)
+			fmt.Printf("Result:\n%s\n", result.OutputText())
 		}
 	}
 
 	return nil
 }
 
+// CMD_askSQL sends the schema of the results database and the question to
+// OpenAI, shows the SQL it returns and, once confirmed, runs it read-only and
+// prints the answer. Typing changes instead of confirming revises the SQL.
+func CMD_askSQL(question string, dbPath string) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no results database at %s, run `query-projects load` first", dbPath)
+	}
+	db, err := store.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	schema, err := store.Schema(db)
+	if err != nil {
+		return err
+	}
+	if schema == "" {
+		return fmt.Errorf("%s has no tables, run `query-projects load` first", dbPath)
+	}
+
+	prompt := fmt.Sprintf("Write one SQLite SELECT statement that answers the question below using this schema. "+
+		"Result tables have a project_path or Project_Path column that matches projects.path, "+
+		"project_topics.project_path and project_metadata.project_path, and a package or Package column for workspace packages. "+
+		"Return only the SQL in a ```sql code block.\n\nSchema:\n%s\nQuestion: %s", schema, question)
+
+	fmt.Println("Generating SQL from OpenAI...")
+	query, err := generateSQL(prompt)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\n%s\n\n", query)
+		fmt.Print("Run this query? (Enter to run, 'no' to cancel, or type changes): ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			// Without an answer, e.g. stdin is closed or not a terminal, don't run it
+			fmt.Println("\nNo answer, not running the query.")
+			return nil
+		}
+		input = strings.TrimSpace(input)
+
+		switch strings.ToLower(input) {
+		case "":
+			summary, err := store.Query(db, query)
+			if err != nil {
+				return fmt.Errorf("run generated SQL: %w", err)
+			}
+			printQueryResults(summary)
+			return nil
+		case "no", "n", "done":
+			return nil
+		}
+
+		fmt.Println("Revising SQL based on input...")
+		revised, err := generateSQL(fmt.Sprintf("%s\n\nThe previous answer was:\n```sql\n%s\n```\nChange it as follows: %s", prompt, query, input))
+		if err != nil {
+			fmt.Printf("\u001B[31mError:\033[0m %v\n", err)
+			continue
+		}
+		query = revised
+	}
+}
+
+func generateSQL(prompt string) (string, error) {
+	responseContent, err := callOpenAIWithSystem("You are a helpful assistant who writes SQLite queries.", prompt)
+	if err != nil {
+		return "", err
+	}
+	query := projects.ExtractSQLCode(responseContent)
+	if query == "" {
+		return "", errors.New("failed to extract SQL from the response")
+	}
+	return query, nil
+}
+
 func callOpenAI(prompt string) (string, error) {
+	return callOpenAIWithSystem("You are a helpful assistant who writes scripts for Deno projects.", prompt)
+}
+
+func callOpenAIWithSystem(system string, prompt string) (string, error) {
 	openAIKey := os.Getenv("OPENAI_API_KEY")
 	if openAIKey == "" {
 		return "", errors.New("please set the OPENAI_API_KEY environment variable")
@@ -119,7 +216,7 @@ func callOpenAI(prompt string) (string, error) {
 		"max_tokens":  1500,
 		"temperature": 0.7,
 		"messages": []map[string]string{
-			{"role": "system", "content": "You are a helpful assistant who writes scripts for Deno projects."},
+			{"role": "system", "content": system},
 			{"role": "user", "content": prompt},
 		},
 	}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export [table...]",
+	Short: "Export the results database to Parquet files with a manifest for warehouses and BI tools",
+	Args:  cobra.ArbitraryArgs,
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
+		dbPath, _ := cmd.Flags().GetString("db")
+		out, _ := cmd.Flags().GetString("out")
+		return CMD_export(dbPath, args, out)
+	}),
+}
+
+func ExportCmdInit(cmd *cobra.Command) {
+	cmd.Flags().StringP("db", "d", store.DefaultPath, "Path to SQLite database file")
+	cmd.Flags().String("out", filepath.Join(projects.ResultsFolder, "export"), "Folder to write the Parquet files and manifest.json to")
+}
+
+// CMD_export writes each table, or every table in the database when none are
+// given, to <out>/<table>.parquet and lists them in <out>/manifest.json. The
+// projects table is written from projects.json when load hasn't added it to
+// the database.
+func CMD_export(dbPath string, tables []string, out string) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no results database at %s, run `query-projects load` first", dbPath)
+	}
+	db, err := store.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	existing, err := store.Tables(db)
+	if err != nil {
+		return err
+	}
+	projectsFromJSON := !slices.Contains(existing, "projects")
+	if len(tables) == 0 {
+		tables = existing
+		if projectsFromJSON {
+			tables = append(tables, "projects")
+		}
+	}
+	for _, table := range tables {
+		if !slices.Contains(existing, table) && table != "projects" {
+			return fmt.Errorf("no table %s in %s", table, dbPath)
+		}
+	}
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return fmt.Errorf("create export folder: %w", err)
+	}
+	manifest := store.Manifest{Database: filepath.ToSlash(dbPath), Generated: time.Now().UTC()}
+	for _, table := range tables {
+		fileName := strings.NewReplacer("/", "_", "\\", "_").Replace(table) + ".parquet"
+		path := filepath.Join(out, fileName)
+		source := table
+		var tableManifest store.TableManifest
+		if table == "projects" && projectsFromJSON {
+			source = projects.ProjectsFile
+			tableManifest, err = exportProjects(path)
+		} else {
+			tableManifest, err = store.ExportParquet(db, table, path)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d rows from %s to %s\n", tableManifest.Rows, source, outputs.CleanPath(path))
+		manifest.Tables = append(manifest.Tables, tableManifest)
+	}
+
+	manifestPath, err := store.WriteManifest(out, manifest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Manifest written to %s\n", outputs.CleanPath(manifestPath))
+	return nil
+}
+
+// exportProjects writes the projects table to path straight from projects.json,
+// for databases that projects weren't loaded into.
+func exportProjects(path string) (store.TableManifest, error) {
+	pj, err := projects.LoadProjects()
+	if err != nil {
+		return store.TableManifest{}, fmt.Errorf("no projects table, run `query-projects load` first: %w", err)
+	}
+	db, err := store.Open(":memory:")
+	if err != nil {
+		return store.TableManifest{}, err
+	}
+	defer db.Close()
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+
+	if err := store.LoadProjects(db, pj); err != nil {
+		return store.TableManifest{}, err
+	}
+	return store.ExportParquet(db, "projects", path)
+}
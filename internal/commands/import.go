@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+var ImportCmd = &cobra.Command{
+	Use:   "import <source>",
+	Short: "Add projects from a URL list, .gitmodules, repo manifest, GitHub export or directory of clones.",
+	Args:  cobra.ExactArgs(1),
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
+		sourceType, _ := cmd.Flags().GetString("type")
+		baseURL, _ := cmd.Flags().GetString("base-url")
+		return CMD_import(args[0], sourceType, baseURL)
+	}),
+}
+
+func ImportCmdInit(cmd *cobra.Command) {
+	cmd.Flags().String("type", "", "Source type (list, gitmodules, manifest, github, scan). Detected from the source when empty.")
+	cmd.Flags().String("base-url", "", "Repo URL that relative .gitmodules and manifest URLs are resolved against (default the origin remote of the source's directory)")
+}
+
+// detectImportType picks a source type from the file name or, for
+// directories, scans for clones.
+func detectImportType(source string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "scan", nil
+	}
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".gitmodules":
+		return "gitmodules", nil
+	case ".xml":
+		return "manifest", nil
+	case ".json":
+		return "github", nil
+	default:
+		return "list", nil
+	}
+}
+
+// CMD_import reads projects from source and adds the ones not already in
+// projects.json. Repositories are not cloned; run `pull` afterwards. Relative
+// URLs in .gitmodules and manifests are resolved against baseURL, or the
+// origin of the repo the source is in.
+func CMD_import(source string, sourceType string, baseURL string) error {
+	projectsList, err := projects.LoadProjects()
+	if err != nil {
+		return err
+	}
+
+	if sourceType == "" {
+		if sourceType, err = detectImportType(source); err != nil {
+			return err
+		}
+	}
+
+	var imported []projects.Project
+	if sourceType == "scan" {
+		imported, err = projects.ScanDirectory(source, projectsList.RootDirectory)
+	} else {
+		var data []byte
+		data, err = os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		if baseURL == "" && (sourceType == "gitmodules" || sourceType == "manifest") {
+			baseURL, _ = projects.OriginURL(filepath.Dir(source))
+		}
+		switch sourceType {
+		case "list":
+			imported, err = projects.ParseURLList(data)
+		case "gitmodules":
+			imported, err = projects.ParseGitmodules(data, baseURL)
+		case "manifest":
+			imported, err = projects.ParseRepoManifest(data, baseURL)
+		case "github":
+			imported, err = projects.ParseGitHubRepoList(data)
+		default:
+			return fmt.Errorf("unsupported import type: %s", sourceType)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("import %s: %w", source, err)
+	}
+
+	var added []projects.Project
+	projectsList.Projects, added = projects.MergeProjects(projectsList.Projects, imported)
+	for _, p := range added {
+		fmt.Printf("%s Added %s\n", projects.ProjectPathFmt(p.Path), p.RepoURL)
+	}
+	if err := projects.SaveProjects(projectsList); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d of %d projects into %s.\n", len(added), len(imported), projects.ProjectsFile)
+	return nil
+}
@@ -1,136 +1,111 @@
 package commands
 
 import (
-	"database/sql"
-	"encoding/csv"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
 	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
 )
 
 var LoadCmd = &cobra.Command{
-	Use:   "load [flags] <csv1> <csv2> …",
-	Short: "Load one or more CSV files into an SQLite database",
+	Use:   "load [flags] <file1> <file2> …",
+	Short: "Load CSV, JSON and NDJSON result files into an SQLite database",
 	Args:  cobra.MinimumNArgs(0),
 	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
 		dbPath, _ := cmd.Flags().GetString("db")
-		return CMD_loadCSVs(dbPath, args)
+		replace, _ := cmd.Flags().GetBool("replace")
+		return CMD_load(dbPath, args, replace)
 	}),
 }
 
 func LoadCmdInit(cmd *cobra.Command) {
-	defaultDB := filepath.Join(projects.ResultsFolder, "results.db")
-	cmd.Flags().StringP("db", "d", defaultDB, "Path to SQLite database file")
+	cmd.Flags().StringP("db", "d", store.DefaultPath, "Path to SQLite database file")
+	cmd.Flags().Bool("replace", false, "Replace each file's table instead of adding rows that aren't loaded yet")
 }
 
-// CMD_loadCSVs loads each CSV in files into the SQLite DB.
-func CMD_loadCSVs(dbPath string, files []string) error {
+// CMD_load loads each CSV, JSON and NDJSON file in files into the SQLite DB.
+// Rows that are already loaded are skipped unless replace drops the tables first.
+func CMD_load(dbPath string, files []string, replace bool) error {
 	fmt.Printf("Loading %s into %s\n", files, dbPath)
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := store.Open(dbPath)
 	if err != nil {
-		return fmt.Errorf("open db: %w", err)
-	}
-	// force the file to be created and connection verified
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("create db: %w", err)
+		return err
 	}
 	defer db.Close()
 
-	// If no files were provided on the CLI, load every *.csv in resultsFolder.
+	// If no files were provided on the CLI, load every result in resultsFolder.
 	if len(files) == 0 {
-		pattern := filepath.Join(projects.ResultsFolder, "*.csv") // e.g. "results/*.csv"
 		var err error
-		files, err = filepath.Glob(pattern)
+		files, err = findResultFiles(projects.ResultsFolder)
 		if err != nil {
-			return fmt.Errorf("listing CSVs in %s: %w", projects.ResultsFolder, err)
+			return err
 		}
 	}
 
-	for _, f := range files {
-		if err := loadSingleCSV(db, f); err != nil {
+	for _, f := range oneFilePerTable(files) {
+		var err error
+		switch filepath.Ext(f) {
+		case ".json", ".ndjson", ".jsonl":
+			err = store.LoadJSON(db, f, replace)
+		default:
+			err = store.LoadCSV(db, f, replace)
+		}
+		if err != nil {
 			return err
 		}
 	}
-	return nil
-}
-
-// loadSingleCSV reads one CSV file and inserts it into db.
-func loadSingleCSV(db *sql.DB, csvPath string) error {
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return fmt.Errorf("open %s: %w", csvPath, err)
-	}
 
-	defer file.Close()
-
-	r := csv.NewReader(file)
-	headers, err := r.Read()
+	// Load projects.json alongside results so they can be joined by project
+	pj, err := projects.LoadProjects()
 	if err != nil {
-		return fmt.Errorf("read header of %s: %w", csvPath, err)
-	}
-	// sanitize headers: trim and replace spaces with underscores
-	for i, h := range headers {
-		headers[i] = strings.ReplaceAll(strings.TrimSpace(h), " ", "_")
+		fmt.Printf("Skipping projects tables: %v\n", err)
+		return nil
 	}
-
-	if err != nil {
-		return fmt.Errorf("read header of %s: %w", csvPath, err)
-	}
-	table := strings.TrimSuffix(filepath.Base(csvPath), filepath.Ext(csvPath))
-	if err := ensureTable(db, table, headers); err != nil {
+	if err := store.LoadProjects(db, pj); err != nil {
 		return err
 	}
-
-	placeholders := strings.Repeat("?,", len(headers))
-	placeholders = placeholders[:len(placeholders)-1]
-	stmtText := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`,
-		table, strings.Join(headers, ","), placeholders)
-	stmt, err := db.Prepare(stmtText)
-	fmt.Printf("SQLITE: %s\n", stmtText)
-	if err != nil {
-		return fmt.Errorf("prepare insert: %w", err)
-	}
-	defer stmt.Close()
-
-	records, err := r.ReadAll()
-	if err != nil {
-		return fmt.Errorf("read rows of %s: %w", csvPath, err)
-	}
-	for _, rec := range records {
-		// Convert []string to []interface{}
-		vals := make([]interface{}, len(rec))
-		for i, v := range rec {
-			vals[i] = v
-		}
-		if _, err := stmt.Exec(vals...); err != nil {
-			return fmt.Errorf("insert row in %s: %w", table, err)
-		}
-	}
-	fmt.Printf("Loaded %d rows from %s into table %s\n", len(records), csvPath, table)
+	fmt.Printf("Loaded %d projects into projects, project_topics and project_metadata\n", len(pj.Projects))
 	return nil
 }
 
-// ensureTable creates table if needed
-func ensureTable(db *sql.DB, name string, cols []string) error {
-	// 1) build the column defs for the first‑time CREATE
-	colDefs := make([]string, len(cols))
-	for i, c := range cols {
-		colDefs[i] = fmt.Sprintf(`"%s" TEXT`, c)
+// oneFilePerTable drops files that would load into the table of an earlier
+// file, such as results/deps.json after results/deps.csv from run -o csv,json,
+// which would put every project in the table twice.
+func oneFilePerTable(files []string) []string {
+	loaded := map[string]string{}
+	var out []string
+	for _, f := range files {
+		table := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if first, ok := loaded[table]; ok {
+			fmt.Printf("Skipping %s, table %s is loaded from %s\n", f, table, first)
+			continue
+		}
+		loaded[table] = f
+		out = append(out, f)
 	}
-	// add qp_created_at with a default
-	colDefs = append(colDefs, `"qp_created_at" TEXT DEFAULT (CURRENT_TIMESTAMP)`)
+	return out
+}
 
-	_, err := db.Exec(fmt.Sprintf(
-		`CREATE TABLE IF NOT EXISTS "%s" (%s);`,
-		name, strings.Join(colDefs, ",")))
-	if err != nil {
-		return err
+// findResultFiles lists the csv, json and ndjson results in folder, csv first. Run metadata
+// and the summaries written next to results aren't results themselves.
+func findResultFiles(folder string) ([]string, error) {
+	var files []string
+	for _, ext := range []string{"csv", "json", "ndjson", "jsonl"} {
+		matches, err := filepath.Glob(filepath.Join(folder, "*."+ext)) // e.g. "results/*.csv"
+		if err != nil {
+			return nil, fmt.Errorf("listing results in %s: %w", folder, err)
+		}
+		for _, match := range matches {
+			name := strings.TrimSuffix(filepath.Base(match), filepath.Ext(match))
+			switch filepath.Ext(name) {
+			case ".meta", ".grouped", ".drift", ".behind", ".trend", ".trend-runs", ".trend-moves":
+				continue
+			}
+			files = append(files, match)
+		}
 	}
-
-	return nil
+	return files, nil
 }
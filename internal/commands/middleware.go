@@ -7,13 +7,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// withMetrics wraps a command function to log its execution duration.
+// withMetrics wraps a command function to log its execution duration, unless
+// its results are going to stdout.
 func withMetrics(fn func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
 		start := time.Now() // Start timing
 		err := fn(cmd, args)
 		duration := time.Since(start) // Calculate duration
-		fmt.Printf("Command '%s' executed in %s\n", cmd.Name(), duration)
+		// Keep machine-readable output clean
+		if toStdout, _ := cmd.Flags().GetBool("stdout"); !toStdout && !cmd.Flags().Changed("format") {
+			fmt.Printf("Command '%s' executed in %s\n", cmd.Name(), duration)
+		}
 		return err
 	}
 }
@@ -42,7 +42,7 @@ func CMD_plan(topics []string, script string) error {
 	// Create repo contexts from project list
 	repos := make([]plan.RepoContext, len(targets))
 	for i, project := range targets {
-		repos[i] = newRepo(&project)
+		repos[i] = newRepo(projectsList, &project)
 	}
 
 	if script != "" {
@@ -95,10 +95,10 @@ func CMD_plan(topics []string, script string) error {
 	return nil
 }
 
-func newRepo(project *projects.Project) plan.RepoContext {
+func newRepo(pj *projects.ProjectsJSON, project *projects.Project) plan.RepoContext {
 	vm := lua.NewState()
 	// Register DSL
-	vm.SetGlobal("run", vm.NewFunction(plan.RunFunc(project)))
+	vm.SetGlobal("run", vm.NewFunction(plan.RunFunc(pj, project)))
 	vm.SetGlobal("value", vm.NewFunction(plan.ValueFunc(project)))
 	vm.SetGlobal("repoName", lua.LString(project.Name))
 	return plan.RepoContext{Project: project, VM: vm}
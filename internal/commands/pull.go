@@ -17,8 +17,9 @@ var PullCmd = &cobra.Command{
 		githubToken, _ := cmd.Flags().GetString("githubToken")
 		githubUser, _ := cmd.Flags().GetString("githubUser")
 		githubUpdateToken, _ := cmd.Flags().GetBool("githubUpdateToken")
+		locked, _ := cmd.Flags().GetBool("locked")
 
-		return CMD_pullRepos(topics, githubToken, githubUser, githubUpdateToken)
+		return CMD_pullRepos(topics, githubToken, githubUser, githubUpdateToken, locked)
 	}),
 }
 
@@ -28,29 +29,85 @@ func PullCmdInit(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("githubToken", githubToken, "Token to pull private github repositories defaults to GITHUB_TOKEN env.")
 	cmd.PersistentFlags().String("githubUser", githubUser, "User for token to pull private github repositories defaults to GITHUB_USER env.")
 	cmd.PersistentFlags().Bool("githubUpdateToken", false, "Run script to update token")
+	cmd.PersistentFlags().Bool("locked", false, fmt.Sprintf("Check out the commits recorded in %s instead of the latest changes", projects.LockFile))
 }
 
 // CMD_pullRepos pulls or clones every repo whose topic matches.
 // It keeps going even if some repos fail and returns a joined error list.
-func CMD_pullRepos(topics []string, githubToken string, githubUser string, githubUpdateToken bool) error {
+// When locked is set every repo is checked out at its commit in projects.lock,
+// otherwise the lock is updated with the commits that were pulled, unless a
+// repo failed, and projects no longer in projects.json are removed from it.
+func CMD_pullRepos(topics []string, githubToken string, githubUser string, githubUpdateToken bool, locked bool) error {
 	projectsList, err := projects.LoadProjects()
 	if err != nil {
 		return err
 	}
 
+	lock, err := projects.LoadLock(projectsList.RootDirectory)
+	if err != nil {
+		return err
+	}
+
 	filtered := projects.FilterProjectsByTopics(projectsList.Projects, topics)
 
 	var errs []error
 
 	for _, p := range filtered {
-		if err := projects.CloneRepository(p.RepoURL, p.Path, githubToken, githubUser, githubUpdateToken, p.Git); err != nil {
+		var err error
+		if locked {
+			err = checkoutLockedProject(p, lock, githubToken, githubUser)
+		} else {
+			err = projects.CloneRepository(p.RepoURL, p.Path, githubToken, githubUser, githubUpdateToken, p.Git)
+			if err == nil {
+				err = lockProject(p, lock)
+			}
+		}
+		if err != nil {
 			wrap := fmt.Errorf("%s %w", projects.ProjectPathFmt(p.Path), err)
 			errs = append(errs, wrap)
 		}
 	}
 
+	if !locked && len(errs) > 0 {
+		// A lock mixing new commits with the old commits of failed projects
+		// would pin a state that was never pulled together
+		fmt.Printf("Not updating %s, %d projects failed to pull\n", projects.LockFile, len(errs))
+	} else if !locked {
+		for _, path := range lock.Prune(projectsList.Projects) {
+			fmt.Printf("Removed %s from %s\n", projects.ProjectPathFmt(path), projects.LockFile)
+		}
+		if err := projects.SaveLock(projectsList.RootDirectory, lock); err != nil {
+			return fmt.Errorf("write %s: %w", projects.LockFile, err)
+		}
+		fmt.Printf("Updated %s\n", projects.LockFile)
+	}
+
 	if len(errs) > 0 {
 		fmt.Printf("\n%s\n", errors.Join(errs...))
 	}
 	return nil
 }
+
+// lockProject records the commit p is currently checked out at.
+func lockProject(p projects.Project, lock *projects.Lock) error {
+	commit, err := projects.ResolveCommit(p.Path)
+	if err != nil {
+		return err
+	}
+	lock.Projects[p.Path] = projects.LockedProject{RepoURL: p.RepoURL, Commit: commit}
+	return nil
+}
+
+// checkoutLockedProject clones p if needed and checks out its locked commit.
+func checkoutLockedProject(p projects.Project, lock *projects.Lock, githubToken string, githubUser string) error {
+	locked, ok := lock.Projects[p.Path]
+	if !ok {
+		return fmt.Errorf("not found in %s, run pull without --locked first", projects.LockFile)
+	}
+	if _, err := os.Stat(p.Path); os.IsNotExist(err) {
+		if err := projects.CloneRepository(p.RepoURL, p.Path, githubToken, githubUser, false, p.Git); err != nil {
+			return err
+		}
+	}
+	return projects.CheckoutCommit(p.Path, locked.Commit)
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+var QueryCmd = &cobra.Command{
+	Use:   "query [sql]",
+	Short: "Run SQL over loaded results, or start a SQL REPL without a query",
+	Args:  cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, _ := cmd.Flags().GetString("db")
+		outputFormats, _ := cmd.Flags().GetStringSlice("output")
+		out, _ := cmd.Flags().GetString("out")
+		return CMD_query(dbPath, strings.Join(args, " "), outputFormats, out)
+	},
+}
+
+func QueryCmdInit(cmd *cobra.Command) {
+	cmd.Flags().StringP("db", "d", store.DefaultPath, "Path to SQLite database file")
+	cmd.Flags().StringSliceP("output", "o", nil, "Comma-separated formats to export the results to (md, csv, json, html)")
+	cmd.Flags().String("out", filepath.Join(projects.ResultsFolder, "query"), "Export path without extension")
+}
+
+// CMD_query runs query against the results database and prints the rows.
+// Without a query it starts a REPL.
+func CMD_query(dbPath string, query string, outputFormats []string, out string) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no results database at %s, run `query-projects load` first", dbPath)
+	}
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if query != "" {
+		summary, err := store.Query(db, query)
+		if err != nil {
+			return err
+		}
+		printQueryResults(summary)
+		for _, format := range outputFormats {
+			if err := exportQueryResults(summary, format, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return queryREPL(db, out)
+}
+
+func printQueryResults(summary outputs.Summary) {
+	if len(summary.Columns) == 0 {
+		fmt.Println("OK")
+		return
+	}
+	outputs.PrintSummary(os.Stdout, summary)
+	fmt.Printf("%d rows\n", len(summary.Rows))
+}
+
+func exportQueryResults(summary outputs.Summary, format string, out string) error {
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return fmt.Errorf("create results folder: %w", err)
+	}
+	return outputs.WriteSummary(os.Stdout, out, format, summary)
+}
+
+// queryREPL reads statements until exit. Statements can span lines and end
+// with a semicolon. .tables, .schema [table] and .export <format> [path] work
+// like their sqlite3 counterparts.
+func queryREPL(db *sql.DB, out string) error {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(store.NewCompleter(db).Complete)
+
+	fmt.Println("SQL REPL over the results database. End statements with ; and type `exit` to quit.")
+
+	var last outputs.Summary
+	var statement strings.Builder
+	for {
+		prompt := "query> "
+		if statement.Len() > 0 {
+			prompt = "  ...> "
+		}
+		input, err := line.Prompt(prompt)
+		if err != nil {
+			break
+		}
+		trimmed := strings.TrimSpace(input)
+		if statement.Len() == 0 && (trimmed == "exit" || trimmed == ".exit" || trimmed == "quit") {
+			break
+		}
+		if trimmed == "" {
+			continue
+		}
+		line.AppendHistory(input)
+
+		if statement.Len() == 0 && strings.HasPrefix(trimmed, ".") {
+			if err := runDotCommand(db, trimmed, last, out); err != nil {
+				fmt.Printf("\u001B[31mError:\033[0m %v\n", err)
+			}
+			continue
+		}
+
+		statement.WriteString(input + "\n")
+		if !strings.HasSuffix(trimmed, ";") {
+			continue
+		}
+		summary, err := store.Query(db, statement.String())
+		statement.Reset()
+		if err != nil {
+			fmt.Printf("\u001B[31mError:\033[0m %v\n", err)
+			continue
+		}
+		printQueryResults(summary)
+		last = summary
+	}
+	return nil
+}
+
+func runDotCommand(db *sql.DB, input string, last outputs.Summary, out string) error {
+	fields := strings.Fields(input)
+	switch fields[0] {
+	case ".tables":
+		tables, err := store.Tables(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(tables, "\n"))
+	case ".schema":
+		tables := fields[1:]
+		if len(tables) == 0 {
+			var err error
+			if tables, err = store.Tables(db); err != nil {
+				return err
+			}
+		}
+		for _, table := range tables {
+			columns, err := store.Columns(db, table)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s(%s)\n", table, strings.Join(columns, ", "))
+		}
+	case ".export":
+		if len(fields) < 2 {
+			return errors.New("usage: .export <md|csv|json|html> [path without extension]")
+		}
+		if len(last.Columns) == 0 {
+			return errors.New("nothing to export, run a query first")
+		}
+		if len(fields) > 2 {
+			out = fields[2]
+		}
+		return exportQueryResults(last, fields[1], out)
+	default:
+		return fmt.Errorf("unknown command %s, expected .tables, .schema or .export", fields[0])
+	}
+	return nil
+}
@@ -5,25 +5,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/analysis"
 	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/policy"
 	"github.com/wcatron/query-projects/internal/projects"
 	"github.com/wcatron/query-projects/internal/scripts"
+	"github.com/wcatron/query-projects/internal/store"
 )
 
 var RunCmd = &cobra.Command{
 	Use:   "run [scriptName]",
 	Short: "Run scripts across all projects in your configuration.",
+	// Failed projects return an error, which isn't a usage problem. Execute
+	// prints the error so cobra doesn't need to.
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
 		// Get the topics from the command line flags
 		topics, _ := cmd.Flags().GetStringSlice("topics")
@@ -31,19 +41,117 @@ var RunCmd = &cobra.Command{
 		all, _ := cmd.Flags().GetBool("all")
 		outputFormats, _ := cmd.Flags().GetStringSlice("output")
 		scriptName, _ := cmd.Flags().GetString("script")
-		return CMD_runScript(scriptName, topics, all, count, outputFormats, args)
+		workspaces, _ := cmd.Flags().GetBool("workspaces")
+		ref, _ := cmd.Flags().GetString("ref")
+		expect, _ := cmd.Flags().GetStringArray("expect")
+		groupBy, _ := cmd.Flags().GetString("group-by")
+		pivot, _ := cmd.Flags().GetString("pivot")
+		aggregates, _ := cmd.Flags().GetStringSlice("aggregate")
+		drift, _ := cmd.Flags().GetString("drift")
+		driftKey, _ := cmd.Flags().GetString("drift-key")
+		target, _ := cmd.Flags().GetString("target")
+		templatePath, _ := cmd.Flags().GetString("template")
+		toStdout, _ := cmd.Flags().GetBool("stdout")
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+		dbPath, _ := cmd.Flags().GetString("db")
+		sqliteMode, _ := cmd.Flags().GetString("sqlite-mode")
+		opts := RunOptions{
+			Count:         count,
+			Log:           os.Stdout,
+			OutputFormats: outputFormats,
+			Workspaces:    workspaces,
+			Ref:           ref,
+			Expect:        expect,
+			Template:      templatePath,
+			Out:           out,
+			DB:            dbPath,
+			SQLiteMode:    sqliteMode,
+			Group: analysis.GroupOptions{
+				GroupBy:    groupBy,
+				Pivot:      pivot,
+				Aggregates: aggregates,
+			},
+			Drift: analysis.DriftOptions{
+				Field:  drift,
+				Key:    driftKey,
+				Target: target,
+			},
+		}
+
+		// Check the report options up front so a typo doesn't lose a whole run
+		if opts.Count || opts.Group.GroupBy != "" {
+			group := opts.Group
+			if group.GroupBy == "" {
+				group.GroupBy = "output"
+			}
+			if err := group.Validate(); err != nil {
+				return err
+			}
+		}
+		if err := opts.Drift.Validate(); err != nil {
+			return err
+		}
+		if !slices.Contains(store.Modes, opts.SQLiteMode) {
+			return fmt.Errorf("unsupported --sqlite-mode %q, expected one of %s", opts.SQLiteMode, strings.Join(store.Modes, ", "))
+		}
+
+		if toStdout || cmd.Flags().Changed("format") {
+			if !slices.Contains(outputs.StreamFormats, format) {
+				return fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(outputs.StreamFormats, ", "))
+			}
+			// Everything else that's printed is a log line, keep it out of the results.
+			opts.Stdout, opts.Format, opts.Log = os.Stdout, format, os.Stderr
+		}
+		return CMD_runScript(scriptName, topics, all, opts, args)
 	}),
 }
 
+// RunOptions holds the run flags that are passed through to each script run.
+type RunOptions struct {
+	Count         bool
+	OutputFormats []string
+	Workspaces    bool
+	Ref           string
+	Expect        []string
+	Template      string
+	Out           string   // Results path pattern, see outputs.ResultsBase
+	Stdout        *os.File // Set to stream results to stdout in Format
+	Format        string
+	Log           io.Writer // Progress, tables and summaries that aren't results, stderr when streaming
+	DB            string    // Database for sqlite output, defaults to results/results.db in the root directory
+	SQLiteMode    string    // append, replace or upsert, see store.WriteResults
+	Group         analysis.GroupOptions
+	Drift         analysis.DriftOptions
+}
+
 func RunCmdInit(cmd *cobra.Command) {
-	cmd.PersistentFlags().Bool("count", false, "Count the unique responses from the script")
+	cmd.PersistentFlags().Bool("count", false, "Count the unique responses from the script, same as --group-by output")
 	cmd.PersistentFlags().Bool("all", false, "Run all scripts")
-	cmd.PersistentFlags().StringSliceP("output", "o", nil, "Comma seperated output formats (md, csv, json)")
+	cmd.PersistentFlags().StringSliceP("output", "o", nil, "Comma-separated output formats (md, csv, json, html, sarif, junit, sqlite)")
 	cmd.PersistentFlags().StringSliceP("topics", "t", nil, "Filter projects by topics")
 	cmd.PersistentFlags().StringP("script", "s", "", "Path to script to run")
+	cmd.PersistentFlags().Bool("workspaces", false, "Run scripts in each package of npm, pnpm, Go and Cargo workspaces")
+	cmd.PersistentFlags().String("ref", "", "Run scripts against a branch, tag or sha in a temporary worktree, falling back to the default branch")
+	cmd.PersistentFlags().StringArray("expect", nil, "Expectation every project must meet, e.g. 'output == true', 'version =~ ^18' or 'count >= 5'. Repeatable")
+	cmd.PersistentFlags().String("group-by", "", "Group results by output, status, topic, metadata.<field> or a column / json field")
+	cmd.PersistentFlags().String("pivot", "", "Add a column counting each value of output, status, topic, metadata.<field> or a column / json field")
+	cmd.PersistentFlags().StringSlice("aggregate", nil, "Aggregates for --group-by: count, percentage, distinct:<column>, min:<column>, max:<column>, semver-max:<column>")
+	cmd.PersistentFlags().String("drift", "", "Report version drift for output or a column / json field holding npm, Go or Maven versions")
+	cmd.PersistentFlags().String("drift-key", "", "Report drift separately for each value of a column / json field, e.g. the dependency name")
+	cmd.PersistentFlags().String("target", "", "List projects on a version below this one, implies --drift output when --drift isn't set")
+	cmd.PersistentFlags().String("out", "", "Results path without extension, relative to the root directory. May use {script}, {date} and {args}")
+	cmd.PersistentFlags().Bool("stdout", false, "Stream results to stdout with no decoration, logging to stderr")
+	cmd.PersistentFlags().String("format", "json", "Format for --stdout: json, ndjson, csv or tsv. Implies --stdout")
+	cmd.PersistentFlags().String("db", "", "Database for --output sqlite, relative to the root directory (default results/results.db)")
+	cmd.PersistentFlags().String("sqlite-mode", store.ModeAppend, "How --output sqlite treats earlier runs of the script: append, replace or upsert")
+	cmd.PersistentFlags().String("template", "", "Render results through a Go text/template file, relative to the root directory")
 }
 
-func CMD_runScript(scriptName string, topics []string, all bool, count bool, outputFormats []string, args []string) error {
+func CMD_runScript(scriptName string, topics []string, all bool, opts RunOptions, args []string) error {
+	if opts.Log == nil {
+		opts.Log = os.Stdout
+	}
 	projectsList, err := projects.LoadProjects()
 	if err != nil {
 		return err
@@ -56,28 +164,41 @@ func CMD_runScript(scriptName string, topics []string, all bool, count bool, out
 		targets = []projects.Project{*targetOveride}
 	}
 
-	scriptInfos, err := getScriptInfos(*projectsList)
+	if opts.Ref != "" {
+		var cleanup func()
+		targets, cleanup = checkoutWorktrees(opts.Log, projectsList.RootDirectory, targets, opts.Ref)
+		defer cleanup()
+	}
+
+	if opts.Workspaces {
+		targets = projects.ExpandWorkspaces(opts.Log, projectsList.RootDirectory, targets)
+	}
+
+	scriptInfos, err := getScriptInfos(opts.Log, *projectsList)
 	if err != nil {
 		return err
 	}
 
 	if all {
+		// Keep running the other scripts when one fails, then report every failure
+		var errs []error
 		for _, scriptInfo := range scriptInfos {
-			if err := runScriptForProjectsList(projectsList, scriptInfo, targets, count, outputFormats, args); err != nil {
-				return fmt.Errorf("error running %s: %w", scriptInfo.Path, err)
+			if err := runScriptForProjectsList(projectsList, scriptInfo, targets, opts, args); err != nil {
+				errs = append(errs, fmt.Errorf("error running %s: %w", scriptInfo.Path, err))
 			}
 		}
+		return errors.Join(errs...)
 	} else {
 		scriptInfo, err := func() (outputs.ScriptInfo, error) {
 			if scriptName != "" {
-				return getScriptInfo(scriptName, *projectsList)
+				return getScriptInfo(opts.Log, scriptName, *projectsList)
 			}
-			return selectScriptInfo(scriptInfos)
+			return selectScriptInfo(opts.Log, scriptInfos)
 		}()
 		if err != nil {
 			return err
 		}
-		if err := runScriptForProjectsList(projectsList, scriptInfo, targets, count, outputFormats, args); err != nil {
+		if err := runScriptForProjectsList(projectsList, scriptInfo, targets, opts, args); err != nil {
 			return fmt.Errorf("error running %s: %w", scriptInfo.Path, err)
 		}
 	}
@@ -85,6 +206,32 @@ func CMD_runScript(scriptName string, topics []string, all bool, count bool, out
 	return nil
 }
 
+// checkoutWorktrees creates a worktree at ref for every target. Projects that
+// can't be checked out are skipped. The returned cleanup removes the worktrees.
+func checkoutWorktrees(w io.Writer, rootDirectory string, targets []projects.Project, ref string) ([]projects.Project, func()) {
+	var checkedOut []projects.Project
+	for _, p := range targets {
+		wt, err := projects.AddWorktree(filepath.Join(rootDirectory, p.Path), ref)
+		if err != nil {
+			fmt.Fprintf(w, "%s Skipping, unable to check out %s: %v\n", projects.ProjectPathFmt(p.Path), ref, err)
+			continue
+		}
+		if wt.Fallback {
+			fmt.Fprintf(w, "%s %s not found, using %s\n", projects.ProjectPathFmt(p.Path), ref, wt.Ref)
+		}
+		p.Worktree = wt
+		checkedOut = append(checkedOut, p)
+	}
+
+	return checkedOut, func() {
+		for _, p := range checkedOut {
+			if err := p.Worktree.Remove(); err != nil {
+				fmt.Fprintf(w, "%s %v\n", projects.ProjectPathFmt(p.Path), err)
+			}
+		}
+	}
+}
+
 // findScriptFiles returns a list of TypeScript files in the scripts folder
 func findScriptFiles(pj projects.ProjectsJSON) ([]string, error) {
 	files, err := os.ReadDir(path.Join(pj.RootDirectory, projects.ScriptsFolder))
@@ -107,12 +254,12 @@ func findScriptFiles(pj projects.ProjectsJSON) ([]string, error) {
 }
 
 // getScriptInfosFromPaths collects information about each script
-func getScriptInfosFromPaths(scriptPaths []string, pj projects.ProjectsJSON) []outputs.ScriptInfo {
+func getScriptInfosFromPaths(w io.Writer, scriptPaths []string, pj projects.ProjectsJSON) []outputs.ScriptInfo {
 	var scriptInfos []outputs.ScriptInfo
 	for _, sp := range scriptPaths {
-		info, err := getScriptInfo(sp, pj)
+		info, err := getScriptInfo(w, sp, pj)
 		if err != nil {
-			fmt.Printf("Error getting info for script %s: %v\n", sp, err)
+			fmt.Fprintf(w, "Error getting info for script %s: %v\n", sp, err)
 			continue
 		}
 		scriptInfos = append(scriptInfos, info)
@@ -121,11 +268,11 @@ func getScriptInfosFromPaths(scriptPaths []string, pj projects.ProjectsJSON) []o
 }
 
 // displayScriptTable shows a formatted table of available scripts
-func displayScriptTable(scriptInfos []outputs.ScriptInfo) {
+func displayScriptTable(w io.Writer, scriptInfos []outputs.ScriptInfo) {
 	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgYellow).SprintfFunc()
 	tbl := table.New("#", "Name", "Version", "Output")
-	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt).WithWriter(w)
 	for i, si := range scriptInfos {
 		tbl.AddRow(
 			fmt.Sprintf("%d", i+1),
@@ -138,13 +285,13 @@ func displayScriptTable(scriptInfos []outputs.ScriptInfo) {
 }
 
 // getScriptInfos Gets script info for all scripts in the
-func getScriptInfos(pj projects.ProjectsJSON) ([]outputs.ScriptInfo, error) {
+func getScriptInfos(w io.Writer, pj projects.ProjectsJSON) ([]outputs.ScriptInfo, error) {
 	scriptPaths, err := findScriptFiles(pj)
 	if err != nil {
 		return []outputs.ScriptInfo{}, err
 	}
 
-	scriptInfos := getScriptInfosFromPaths(scriptPaths, pj)
+	scriptInfos := getScriptInfosFromPaths(w, scriptPaths, pj)
 	if len(scriptInfos) == 0 {
 		return []outputs.ScriptInfo{}, errors.New("no valid scripts found")
 	}
@@ -152,12 +299,12 @@ func getScriptInfos(pj projects.ProjectsJSON) ([]outputs.ScriptInfo, error) {
 }
 
 // getScriptInfo executes a script with the --info flag and returns the parsed JSON output.
-func getScriptInfo(scriptPath string, pj projects.ProjectsJSON) (outputs.ScriptInfo, error) {
+func getScriptInfo(w io.Writer, scriptPath string, pj projects.ProjectsJSON) (outputs.ScriptInfo, error) {
 	cmd := exec.Command("deno", "run", "--allow-all", scriptPath, "--info")
 	cmd.Dir = pj.RootDirectory
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("%s \n%s", scripts.ScriptPathFmt(scriptPath), output)
+		fmt.Fprintf(w, "%s \n%s", scripts.ScriptPathFmt(scriptPath), output)
 		return outputs.ScriptInfo{}, fmt.Errorf("failed to run script with --info: %w", err)
 	}
 
@@ -170,10 +317,10 @@ func getScriptInfo(scriptPath string, pj projects.ProjectsJSON) (outputs.ScriptI
 	return info, nil
 }
 
-func selectScriptInfo(scriptInfos []outputs.ScriptInfo) (outputs.ScriptInfo, error) {
-	displayScriptTable(scriptInfos)
+func selectScriptInfo(w io.Writer, scriptInfos []outputs.ScriptInfo) (outputs.ScriptInfo, error) {
+	displayScriptTable(w, scriptInfos)
 
-	choice, err := promptUserSelectNumber(len(scriptInfos))
+	choice, err := promptUserSelectNumber(w, len(scriptInfos))
 	if err != nil {
 		return outputs.ScriptInfo{}, err
 	}
@@ -182,8 +329,8 @@ func selectScriptInfo(scriptInfos []outputs.ScriptInfo) (outputs.ScriptInfo, err
 }
 
 // promptUserSelectNumber prompts the user to select a script and returns the index
-func promptUserSelectNumber(validLength int) (int, error) {
-	fmt.Print("Enter a number: ")
+func promptUserSelectNumber(w io.Writer, validLength int) (int, error) {
+	fmt.Fprint(w, "Enter a number: ")
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
@@ -195,7 +342,24 @@ func promptUserSelectNumber(validLength int) (int, error) {
 }
 
 // runScriptForProjectsList executes the specified .ts script against all projects.
-func runScriptForProjectsList(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInfo, projectsList []projects.Project, count bool, outputFormats []string, args []string) error {
+func runScriptForProjectsList(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInfo, projectsList []projects.Project, opts RunOptions, args []string) error {
+	started := time.Now()
+	expectations, err := policy.ParseAll(append(slices.Clone(scriptInfo.Expect), opts.Expect...))
+	if err != nil {
+		return err
+	}
+	group := opts.Group
+	if opts.Count && group.GroupBy == "" {
+		group.GroupBy = "output"
+	}
+	projectsList = slices.DeleteFunc(slices.Clone(projectsList), func(project projects.Project) bool {
+		excluded := pj.RunConfigFor(project, scriptInfo.Path).Excluded
+		if excluded {
+			fmt.Fprintf(opts.Log, "%s Skipping excluded script %s\n", projects.ProjectPathFmt(project.Path), scripts.ScriptPathFmt(scriptInfo.Path))
+		}
+		return excluded
+	})
+
 	var wg sync.WaitGroup
 	resultsChan := make(chan outputs.Result, len(projectsList))
 
@@ -203,10 +367,10 @@ func runScriptForProjectsList(pj *projects.ProjectsJSON, scriptInfo outputs.Scri
 		wg.Add(1)
 		go func(project projects.Project, index int) {
 			defer wg.Done()
-			r, err := scripts.RunScriptForProject(pj, scriptInfo, project.Path, args, true)
+			r, err := scripts.RunScriptForProject(pj, scriptInfo, project, args, opts.Log)
 			r.Index = index
 			if err != nil {
-				fmt.Printf("Error in project %s: %v\n", project.Name, err)
+				fmt.Fprintf(opts.Log, "Error in project %s: %v\n", project.Name, err)
 			}
 			resultsChan <- r
 		}(p, i)
@@ -216,8 +380,16 @@ func runScriptForProjectsList(pj *projects.ProjectsJSON, scriptInfo outputs.Scri
 	close(resultsChan)
 
 	var results []outputs.Result = collectResults(resultsChan, len(projectsList))
+	failed := 0
+	for i := range results {
+		results[i].Violations = policy.Evaluate(scriptInfo, results[i], expectations)
+		if len(results[i].Violations) > 0 {
+			failed++
+		}
+	}
 
-	if len(outputFormats) == 0 {
+	outputFormats := opts.OutputFormats
+	if len(outputFormats) == 0 && opts.Stdout == nil {
 		if scriptInfo.Output == "text" {
 			outputFormats = []string{"csv", "md"}
 		} else {
@@ -225,44 +397,159 @@ func runScriptForProjectsList(pj *projects.ProjectsJSON, scriptInfo outputs.Scri
 		}
 	}
 
-	// If count flag is enabled, count unique responses and print the table
-	if count {
-		printUniqueResponsesToConsole(results)
-	} else {
-		outputs.PrintToConsole(results)
+	// When grouping, print the grouped table instead of every project's output
+	var summary outputs.Summary
+	if group.GroupBy != "" {
+		if summary, err = analysis.Group(scriptInfo, results, pj.Projects, group); err != nil {
+			return err
+		}
+		outputs.PrintSummary(opts.Log, summary)
+	} else if opts.Stdout == nil {
+		outputs.PrintToConsole(opts.Log, results)
+	}
+	if opts.Stdout != nil {
+		if err := outputs.WriteStream(opts.Stdout, opts.Format, scriptInfo, results); err != nil {
+			return fmt.Errorf("write results to stdout: %w", err)
+		}
+	}
+
+	lockHash, err := projects.LockHash(pj.RootDirectory)
+	if err != nil {
+		fmt.Fprintf(opts.Log, "Unable to hash %s: %v\n", projects.LockFile, err)
+	}
+	meta := outputs.RunMetadata{
+		Script:   scriptInfo.Path,
+		Args:     args,
+		Ref:      opts.Ref,
+		LockHash: lockHash,
+		Started:  started,
+		Duration: time.Since(started).String(),
+	}
+
+	base := outputs.ResultsBase(pj.RootDirectory, opts.Out, scriptInfo.Path, args, started)
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return fmt.Errorf("create results folder: %w", err)
 	}
 
 	for _, format := range outputFormats {
 		var err error
 		switch format {
 		case "md":
-			err = outputs.WriteTable(pj.RootDirectory, scriptInfo.Path, results)
+			err = outputs.WriteTable(opts.Log, base, results)
 		case "csv":
-			err = outputs.WriteCSVTable(pj.RootDirectory, scriptInfo, results)
+			err = outputs.WriteCSVTable(opts.Log, base, scriptInfo, results)
 		case "json":
-			err = outputs.WriteJSONOutput(pj.RootDirectory, scriptInfo.Path, results)
+			err = outputs.WriteJSONOutput(opts.Log, base, scriptInfo, results)
+		case "html":
+			err = outputs.WriteHTMLReport(opts.Log, base, scriptInfo, results, pj.Projects)
+		case "sarif":
+			err = outputs.WriteSARIFOutput(opts.Log, base, scriptInfo, results)
+		case "junit":
+			err = outputs.WriteJUnitOutput(opts.Log, base, scriptInfo, results)
+		case "sqlite":
+			err = writeSQLiteResults(pj.RootDirectory, scriptInfo, results, meta, opts)
 		default:
-			fmt.Printf("Unsupported output format: %s\n", format)
+			fmt.Fprintf(opts.Log, "Unsupported output format: %s\n", format)
 		}
 		if err != nil {
-			fmt.Printf("\u001B[31mError:\033[0m Failed to write %s\n%s\n", format, err)
+			fmt.Fprintf(opts.Log, "\u001B[31mError:\033[0m Failed to write %s\n%s\n", format, err)
 		}
 	}
+	templatePath := opts.Template
+	if templatePath == "" {
+		templatePath = scriptInfo.Template
+	}
+	if templatePath != "" {
+		if !filepath.IsAbs(templatePath) {
+			templatePath = filepath.Join(pj.RootDirectory, templatePath)
+		}
+		if err := outputs.WriteTemplateOutput(opts.Log, base, templatePath, scriptInfo, results, pj.Projects); err != nil {
+			fmt.Fprintf(opts.Log, "\u001B[31mError:\033[0m Failed to write template\n%s\n", err)
+		}
+	}
+	if group.GroupBy != "" {
+		writeSummaries(opts.Log, base, "grouped", outputFormats, summary)
+	}
 
+	if opts.Drift.Field != "" || opts.Drift.Target != "" {
+		overview, behind, err := analysis.Drift(scriptInfo, results, pj.Projects, opts.Drift)
+		if err != nil {
+			return err
+		}
+		outputs.PrintSummary(opts.Log, overview)
+		writeSummaries(opts.Log, base, "drift", outputFormats, overview)
+		if opts.Drift.Target != "" {
+			outputs.PrintSummary(opts.Log, behind)
+			fmt.Fprintf(opts.Log, "%d versions behind %s\n", len(behind.Rows), opts.Drift.Target)
+			writeSummaries(opts.Log, base, "behind", outputFormats, behind)
+		}
+	}
+
+	if err := outputs.WriteRunMetadata(opts.Log, base, meta); err != nil {
+		fmt.Fprintf(opts.Log, "\u001B[31mError:\033[0m Failed to write run metadata\n%s\n", err)
+	}
+
+	if len(expectations) > 0 || failed > 0 {
+		printPolicySummary(opts.Log, results, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(results))
+	}
 	return nil
 }
 
-func printUniqueResponsesToConsole(results []outputs.Result) {
-	responseCounts := make(map[string]int)
-	for _, r := range results {
-		responseCounts[r.StdoutText]++
+// printPolicySummary lists every project that failed or didn't meet an
+// expectation, followed by the pass and fail counts.
+func printPolicySummary(w io.Writer, results []outputs.Result, failed int) {
+	if failed > 0 {
+		tbl := table.New("Project", "Violation").WithWriter(w)
+		for _, r := range results {
+			for _, violation := range r.Violations {
+				tbl.AddRow(r.Label(), violation)
+			}
+		}
+		tbl.Print()
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+}
+
+// writeSummaries writes a summary next to the script's results as
+// <base>.<suffix>.<format>. Formats that can't hold a summary are skipped.
+func writeSummaries(w io.Writer, base string, suffix string, formats []string, summary outputs.Summary) {
+	for _, format := range formats {
+		if format == "sarif" || format == "junit" || format == "sqlite" {
+			continue
+		}
+		if err := outputs.WriteSummary(w, base+"."+suffix, format, summary); err != nil {
+			fmt.Fprintf(w, "\u001B[31mError:\033[0m Failed to write %s %s\n%s\n", suffix, format, err)
+		}
 	}
+}
 
-	tbl := table.New("Unique Response", "Count")
-	for response, count := range responseCounts {
-		tbl.AddRow(strings.TrimSpace(response), fmt.Sprintf("%d", count))
+// writeSQLiteResults writes the run and its results to the results database.
+func writeSQLiteResults(rootDirectory string, scriptInfo outputs.ScriptInfo, results []outputs.Result, meta outputs.RunMetadata, opts RunOptions) error {
+	dbPath := opts.DB
+	if dbPath == "" {
+		dbPath = store.DefaultPath
 	}
-	tbl.Print()
+	if !filepath.IsAbs(dbPath) {
+		dbPath = filepath.Join(rootDirectory, dbPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return fmt.Errorf("create results folder: %w", err)
+	}
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runID, err := store.WriteResults(db, scriptInfo, results, meta, opts.SQLiteMode)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.Log, "Results written to %s as run %d in %s\n", outputs.CleanPath(dbPath), runID, store.ResultsTable(scriptInfo.Path))
+	return nil
 }
 
 func collectResults(resultsChan <-chan outputs.Result, total int) []outputs.Result {
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/scripts"
+	"github.com/wcatron/query-projects/internal/server"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+var ServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve projects, scripts, runs and the results database over a local JSON API",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		token, _ := cmd.Flags().GetString("token")
+		dbPath, _ := cmd.Flags().GetString("db")
+		return CMD_serve(addr, token, dbPath)
+	},
+}
+
+func ServeCmdInit(cmd *cobra.Command) {
+	cmd.Flags().String("addr", "127.0.0.1:8080", "Address to listen on")
+	cmd.Flags().String("token", "", "Bearer token clients must send, defaults to $QUERY_PROJECTS_TOKEN or a random token")
+	cmd.Flags().StringP("db", "d", "", "Path to SQLite database file, relative to the root directory (default results/results.db)")
+}
+
+// CMD_serve serves the API in the server package until interrupted.
+func CMD_serve(addr string, token string, dbPath string) error {
+	pj, err := projects.LoadProjects()
+	if err != nil {
+		return err
+	}
+	if dbPath == "" {
+		dbPath = store.DefaultPath
+	}
+	if !filepath.IsAbs(dbPath) {
+		dbPath = filepath.Join(pj.RootDirectory, dbPath)
+	}
+
+	if token == "" {
+		token = os.Getenv("QUERY_PROJECTS_TOKEN")
+	}
+	if token == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return fmt.Errorf("generate token: %w", err)
+		}
+		token = hex.EncodeToString(random)
+		fmt.Printf("Generated token: %s\n", token)
+		// The dashboard picks the token up from the link
+		fmt.Printf("Open the dashboard at http://%s/#token=%s\n", addr, token)
+	}
+
+	handler, err := server.New(server.Options{
+		Token:    token,
+		DBPath:   dbPath,
+		Projects: projects.LoadProjects,
+		Scripts: func() ([]outputs.ScriptInfo, error) {
+			pj, err := projects.LoadProjects()
+			if err != nil {
+				return nil, err
+			}
+			return serveScripts.infos(*pj)
+		},
+		Run: serveRun,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving the dashboard on http://%s and the API on http://%s/api\n", addr, addr)
+	return http.ListenAndServe(addr, handler)
+}
+
+// scriptInfoCache keeps the --info of each script until the file changes, so
+// listing scripts doesn't run every script on every request.
+type scriptInfoCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedScriptInfo
+}
+
+type cachedScriptInfo struct {
+	modTime time.Time
+	info    outputs.ScriptInfo
+}
+
+var serveScripts = &scriptInfoCache{entries: map[string]cachedScriptInfo{}}
+
+// infos returns the info of every script in the scripts folder, skipping
+// scripts whose info can't be read like getScriptInfos.
+func (c *scriptInfoCache) infos(pj projects.ProjectsJSON) ([]outputs.ScriptInfo, error) {
+	scriptPaths, err := findScriptFiles(pj)
+	if err != nil {
+		return nil, err
+	}
+	var scriptInfos []outputs.ScriptInfo
+	for _, sp := range scriptPaths {
+		info, err := c.info(sp, pj)
+		if err != nil {
+			fmt.Printf("Error getting info for script %s: %v\n", sp, err)
+			continue
+		}
+		scriptInfos = append(scriptInfos, info)
+	}
+	if len(scriptInfos) == 0 {
+		return nil, errors.New("no valid scripts found")
+	}
+	return scriptInfos, nil
+}
+
+// info returns the script's info, running it with --info only when the file
+// changed since it was last read.
+func (c *scriptInfoCache) info(scriptPath string, pj projects.ProjectsJSON) (outputs.ScriptInfo, error) {
+	stat, err := os.Stat(filepath.Join(pj.RootDirectory, scriptPath))
+	if err != nil {
+		return outputs.ScriptInfo{}, err
+	}
+	c.mu.Lock()
+	cached, ok := c.entries[scriptPath]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(stat.ModTime()) {
+		return cached.info, nil
+	}
+
+	info, err := getScriptInfo(os.Stdout, scriptPath, pj)
+	if err != nil {
+		return outputs.ScriptInfo{}, err
+	}
+	c.mu.Lock()
+	c.entries[scriptPath] = cachedScriptInfo{modTime: stat.ModTime(), info: info}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// serveRun runs a script requested through the API across the projects
+// matching its topics. Unlike run it doesn't write result files, the results
+// are returned to the client, and each project's progress is logged for the
+// dashboard.
+func serveRun(req server.RunRequest, progress func(completed, total int), log func(project, message string)) (outputs.ScriptInfo, []outputs.Result, error) {
+	pj, err := projects.LoadProjects()
+	if err != nil {
+		return outputs.ScriptInfo{}, nil, err
+	}
+	// Never run a path from the request that isn't a script in the scripts folder
+	scriptPaths, err := findScriptFiles(*pj)
+	if err != nil {
+		return outputs.ScriptInfo{}, nil, err
+	}
+	if !slices.Contains(scriptPaths, req.Script) {
+		return outputs.ScriptInfo{}, nil, fmt.Errorf("no script %s in the scripts folder", req.Script)
+	}
+	scriptInfo, err := serveScripts.info(req.Script, *pj)
+	if err != nil {
+		return outputs.ScriptInfo{}, nil, err
+	}
+
+	var targets []projects.Project
+	for _, project := range projects.FilterProjectsByTopics(pj.Projects, req.Topics) {
+		if !pj.RunConfigFor(project, scriptInfo.Path).Excluded {
+			targets = append(targets, project)
+		}
+	}
+	progress(0, len(targets))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+	resultsChan := make(chan outputs.Result, len(targets))
+	for i, p := range targets {
+		wg.Add(1)
+		go func(project projects.Project, index int) {
+			defer wg.Done()
+			log(project.Path, "Running "+scriptInfo.Path)
+			r, err := scripts.RunScriptForProject(pj, scriptInfo, project, req.Args, nil)
+			r.Index = index
+			if err != nil {
+				fmt.Printf("Error in project %s: %v\n", project.Name, err)
+			}
+			logResult(log, r, err)
+			resultsChan <- r
+
+			mu.Lock()
+			completed++
+			progress(completed, len(targets))
+			mu.Unlock()
+		}(p, i)
+	}
+	wg.Wait()
+	close(resultsChan)
+
+	return scriptInfo, collectResults(resultsChan, len(targets)), nil
+}
+
+// logResult logs a project's stderr, warnings and status once it finishes.
+func logResult(log func(project, message string), r outputs.Result, err error) {
+	for _, line := range strings.Split(strings.TrimSpace(r.StderrText), "\n") {
+		if line != "" {
+			log(r.Label(), line)
+		}
+	}
+	for _, warning := range r.Warnings {
+		log(r.Label(), "Warning: "+warning)
+	}
+	switch {
+	case err != nil:
+		log(r.Label(), fmt.Sprintf("%s: %v", r.Status, err))
+	case len(r.Rows) > 0:
+		log(r.Label(), fmt.Sprintf("%s, %d rows", r.Status, len(r.Rows)))
+	default:
+		log(r.Label(), r.Status)
+	}
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wcatron/query-projects/internal/analysis"
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+var TrendCmd = &cobra.Command{
+	Use:   "trend <script>",
+	Short: "Compare the runs of a script saved with --output sqlite over time",
+	Args:  cobra.ExactArgs(1),
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
+		dbPath, _ := cmd.Flags().GetString("db")
+		by, _ := cmd.Flags().GetString("by")
+		where, _ := cmd.Flags().GetString("where")
+		var scriptArgs []string
+		if cmd.Flags().Changed("args") {
+			value, _ := cmd.Flags().GetString("args")
+			scriptArgs = append([]string{}, strings.Fields(value)...)
+		}
+		bucket, _ := cmd.Flags().GetString("bucket")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outputFormats, _ := cmd.Flags().GetStringSlice("output")
+		out, _ := cmd.Flags().GetString("out")
+		return CMD_trend(dbPath, args[0], by, where, scriptArgs, limit, analysis.TrendOptions{Bucket: bucket}, outputFormats, out)
+	}),
+}
+
+func TrendCmdInit(cmd *cobra.Command) {
+	cmd.Flags().StringP("db", "d", store.DefaultPath, "Path to SQLite database file")
+	cmd.Flags().String("by", "", "Column to count projects by (default the script's first column)")
+	cmd.Flags().String("where", "", "SQL condition on the results table, e.g. \"name = 'typescript'\"")
+	cmd.Flags().String("args", "", "Compare runs made with these script args (default the args of the latest run)")
+	cmd.Flags().String("bucket", "", "Count versions by major or minor version")
+	cmd.Flags().Int("limit", 12, "Number of most recent runs to compare, 0 for all")
+	cmd.Flags().StringSliceP("output", "o", nil, "Comma-separated formats to export the report to (md, csv, json, html)")
+	cmd.Flags().String("out", "", "Export path without extension (default results/<script>)")
+}
+
+// CMD_trend prints how the values of a script's results changed across its
+// runs and which projects moved in the latest run. Only runs made with
+// scriptArgs are compared, nil compares runs with the args of the latest run.
+func CMD_trend(dbPath string, script string, by string, where string, scriptArgs []string, limit int, opts analysis.TrendOptions, outputFormats []string, out string) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no results database at %s, run a script with --output sqlite first", dbPath)
+	}
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runs, err := store.TrendRuns(db, script, by, where, scriptArgs, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) > 0 && len(runs[0].Args) > 0 {
+		fmt.Printf("Comparing runs with args %s\n", strings.Join(runs[0].Args, " "))
+	}
+	report, err := analysis.Trend(runs, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", script, err)
+	}
+
+	outputs.PrintSummary(os.Stdout, report.Counts)
+	outputs.PrintSummary(os.Stdout, report.Runs)
+	if len(report.Moves.Rows) > 0 {
+		outputs.PrintSummary(os.Stdout, report.Moves)
+	}
+
+	if len(outputFormats) == 0 {
+		return nil
+	}
+	if out == "" {
+		out = filepath.Join(projects.ResultsFolder, outputs.ScriptName(script))
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return fmt.Errorf("create results folder: %w", err)
+	}
+	writeSummaries(os.Stdout, out, "trend", outputFormats, report.Counts)
+	writeSummaries(os.Stdout, out, "trend-runs", outputFormats, report.Runs)
+	writeSummaries(os.Stdout, out, "trend-moves", outputFormats, report.Moves)
+	return nil
+}
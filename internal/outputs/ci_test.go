@@ -0,0 +1,71 @@
+package outputs
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func ciTestResults() []Result {
+	return []Result{
+		{ProjectPath: "./projects/a", Status: "Success", Findings: []Finding{
+			{RuleID: "no-any", Level: "error", Message: "Unexpected any", File: "src/index.ts", Line: 12, Column: 5},
+			{Message: "TODO left in code", File: "README.md"},
+		}},
+		{ProjectPath: "./projects/b", Status: "Failed (exit code 1)", StderrText: "boom"},
+		{ProjectPath: "./projects/c", Status: "Success", Rows: []Row{{"ok"}}},
+		{ProjectPath: "./projects/d", Package: "web", Dir: "packages/web", Status: "Success", Findings: []Finding{
+			{Message: "Unused export", File: "src/index.ts"},
+		}},
+	}
+}
+
+func TestCreateSARIFLog(t *testing.T) {
+	info := ScriptInfo{Path: "scripts/lint.ts", Output: "text"}
+	log := createSARIFLog(info, ciTestResults())
+
+	results := log.Runs[0].Results
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	first := results[0]
+	if first.RuleID != "no-any" || first.Level != "error" || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "projects/a/src/index.ts" {
+		t.Errorf("Unexpected first result %+v", first)
+	}
+	if region := first.Locations[0].PhysicalLocation.Region; region == nil || region.StartLine != 12 || region.StartColumn != 5 {
+		t.Errorf("Expected a region at 12:5, got %+v", region)
+	}
+	if results[1].RuleID != "lint" || results[1].Level != "warning" || results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("Expected the script name as rule and no region, got %+v", results[1])
+	}
+	if results[2].Level != "error" || results[2].Message.Text != "Failed (exit code 1): boom" {
+		t.Errorf("Expected an error for the failed project, got %+v", results[2])
+	}
+	if uri := results[3].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "projects/d/packages/web/src/index.ts" {
+		t.Errorf("Expected the file in the package directory, got %s", uri)
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Errorf("Expected 2 rules, got %+v", log.Runs[0].Tool.Driver.Rules)
+	}
+}
+
+func TestCreateJUnitReport(t *testing.T) {
+	info := ScriptInfo{Path: "scripts/lint.ts", Output: "text"}
+	report := createJUnitReport(info, ciTestResults())
+
+	if report.Tests != 4 || report.Failures != 1 {
+		t.Errorf("Expected 4 tests and 1 failure, got %d and %d", report.Tests, report.Failures)
+	}
+	failed := report.Suites[0].TestCases[1]
+	if failed.Failure == nil || failed.Failure.Message != "Failed (exit code 1)" || failed.Failure.Text != "boom" {
+		t.Errorf("Unexpected failed test case %+v", failed)
+	}
+
+	data, err := xml.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed to marshal report: %v", err)
+	}
+	if !strings.Contains(string(data), `<testcase classname="lint" name="./projects/c"><system-out>ok</system-out></testcase>`) {
+		t.Errorf("Unexpected XML %s", data)
+	}
+}
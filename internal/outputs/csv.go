@@ -3,11 +3,10 @@ package outputs
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"slices"
 	"strings"
-
-	"github.com/wcatron/query-projects/internal/projects"
 )
 
 // FormatOutput formats CSV output based on column headers
@@ -18,13 +17,10 @@ func FormatOutput(csvText string, columns []string) string {
 	return sb.String()
 }
 
-// WriteCSVTable creates a .csv file summarizing the results
-func WriteCSVTable(rootDirectory string, info ScriptInfo, results []Result) error {
-	filename := filepath.Base(info.Path)
-	resultsFilenameForScript := strings.TrimSuffix(filename, ".ts")
-
+// WriteCSVTable creates a .csv file summarizing the results at base.csv
+func WriteCSVTable(log io.Writer, base string, info ScriptInfo, results []Result) error {
 	// Open the CSV file for writing
-	tableFilePath := filepath.Join(rootDirectory, projects.ResultsFolder, resultsFilenameForScript+".csv")
+	tableFilePath := base + ".csv"
 	file, err := os.Create(tableFilePath)
 	if err != nil {
 		return err
@@ -32,10 +28,25 @@ func WriteCSVTable(rootDirectory string, info ScriptInfo, results []Result) erro
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := writeCSVRows(writer, info, results); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
+	return nil
+}
 
-	// Write headers
-	headers := []string{"Project Path", "Status"}
+// writeCSVRows writes a header and a row per result row, keeping a row for
+// projects without output so every project is listed. Workspace packages get
+// a Package column next to their project's path.
+func writeCSVRows(writer *csv.Writer, info ScriptInfo, results []Result) error {
+	withPackage := slices.ContainsFunc(results, func(r Result) bool { return r.Package != "" })
+	headers := []string{"Project Path"}
+	if withPackage {
+		headers = append(headers, "Package")
+	}
+	headers = append(headers, "Status")
+	fixed := len(headers)
 	if len(info.Columns) > 0 {
 		headers = append(headers, info.Columns...)
 	} else {
@@ -46,16 +57,21 @@ func WriteCSVTable(rootDirectory string, info ScriptInfo, results []Result) erro
 	}
 
 	for _, r := range results {
-		lines := strings.Split(r.StdoutText, "\n")
-		for _, line := range lines {
-			values := strings.Split(line, ",")
-			row := append([]string{r.ProjectPath, r.Status}, values...)
+		rows := r.Rows
+		if len(rows) == 0 {
+			rows = []Row{make(Row, len(headers)-fixed)}
+		}
+		for _, values := range rows {
+			row := []string{r.ProjectPath}
+			if withPackage {
+				row = append(row, r.Package)
+			}
+			row = append(append(row, r.Status), values.Cells()...)
 			if err := writer.Write(row); err != nil {
 				return err
 			}
 		}
 	}
-
-	fmt.Printf("Results written to %s\n", CleanPath(tableFilePath))
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
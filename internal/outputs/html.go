@@ -0,0 +1,131 @@
+package outputs
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+//go:embed templates/report.html.tmpl
+var htmlTemplates embed.FS
+
+var htmlReportTemplate = template.Must(template.ParseFS(htmlTemplates, "templates/report.html.tmpl"))
+
+type htmlStatusCount struct {
+	Status string
+	Class  string
+	Count  int
+}
+
+type htmlRow struct {
+	Label       string
+	Status      string
+	StatusClass string
+	Topics      []string
+	Language    string
+	Cells       []string
+	Stderr      string
+}
+
+type htmlReport struct {
+	Title        string
+	Script       string
+	Columns      []string
+	Rows         []htmlRow
+	ProjectCount int
+	RowCount     int
+	StatusCounts []htmlStatusCount
+}
+
+// statusClass maps a result status to its badge style
+func statusClass(status string) string {
+	switch {
+	case status == "Success":
+		return "success"
+	case strings.HasPrefix(status, "Malformed"):
+		return "malformed"
+	case strings.HasPrefix(status, "Failed"):
+		return "failed"
+	default:
+		return "error"
+	}
+}
+
+// createHTMLReport collects the template data for a script's results
+func createHTMLReport(info ScriptInfo, results []Result, projectsList []projects.Project) htmlReport {
+	columns := info.Columns
+	if len(columns) == 0 {
+		columns = []string{"Output"}
+	}
+
+	report := htmlReport{
+		Title:        strings.TrimSuffix(filepath.Base(info.Path), filepath.Ext(info.Path)),
+		Script:       info.Path,
+		Columns:      columns,
+		ProjectCount: len(results),
+	}
+
+	statusCounts := make(map[string]int)
+	for _, r := range results {
+		statusCounts[r.Status]++
+
+		var topics []string
+		var language string
+		if project := projects.FindProject(projectsList, r.ProjectPath); project != nil {
+			topics = project.Topics
+			language = project.MetadataValue("language")
+		}
+
+		rows := r.Rows
+		if len(rows) == 0 {
+			rows = []Row{make(Row, len(columns))}
+		}
+		for _, row := range rows {
+			report.Rows = append(report.Rows, htmlRow{
+				Label:       r.Label(),
+				Status:      r.Status,
+				StatusClass: statusClass(r.Status),
+				Topics:      topics,
+				Language:    language,
+				Cells:       row.Cells(),
+				Stderr:      r.StderrText,
+			})
+		}
+	}
+	report.RowCount = len(report.Rows)
+
+	for status, count := range statusCounts {
+		report.StatusCounts = append(report.StatusCounts, htmlStatusCount{Status: status, Class: statusClass(status), Count: count})
+	}
+	sort.Slice(report.StatusCounts, func(i, j int) bool {
+		return report.StatusCounts[i].Status < report.StatusCounts[j].Status
+	})
+
+	return report
+}
+
+// WriteHTMLReport creates a standalone .html report with a sortable, filterable
+// table of the results and the topics and language of each project
+func WriteHTMLReport(log io.Writer, base string, info ScriptInfo, results []Result, projectsList []projects.Project) error {
+
+	tableFilePath := base + ".html"
+	file, err := os.Create(tableFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := htmlReportTemplate.Execute(file, createHTMLReport(info, results, projectsList)); err != nil {
+		return fmt.Errorf("render html report: %w", err)
+	}
+
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
+	return nil
+}
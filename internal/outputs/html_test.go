@@ -0,0 +1,38 @@
+package outputs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+func TestCreateHTMLReport(t *testing.T) {
+	info := ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	results := []Result{
+		{ProjectPath: "./projects/a", Status: "Success", Rows: []Row{{"react", "18.2.0"}, {"<script>", "1"}}},
+		{ProjectPath: "./projects/b", Status: "Failed (exit code 1)", StderrText: "boom"},
+	}
+	projectsList := []projects.Project{
+		{Path: "./projects/a", Topics: []string{"web"}, Metadata: map[string]any{"language": "TypeScript"}},
+	}
+
+	report := createHTMLReport(info, results, projectsList)
+	if report.ProjectCount != 2 || report.RowCount != 3 {
+		t.Errorf("Expected 2 projects and 3 rows, got %d and %d", report.ProjectCount, report.RowCount)
+	}
+	if report.Rows[0].Language != "TypeScript" || report.Rows[0].Topics[0] != "web" {
+		t.Errorf("Expected project metadata on rows, got %+v", report.Rows[0])
+	}
+	if report.Rows[2].StatusClass != "failed" || len(report.Rows[2].Cells) != 2 {
+		t.Errorf("Expected an empty failed row, got %+v", report.Rows[2])
+	}
+
+	var sb strings.Builder
+	if err := htmlReportTemplate.Execute(&sb, report); err != nil {
+		t.Fatalf("Failed to render report: %v", err)
+	}
+	if strings.Contains(sb.String(), "<td><script></td>") {
+		t.Errorf("Expected output values to be escaped")
+	}
+}
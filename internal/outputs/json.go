@@ -3,40 +3,78 @@ package outputs
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
-
-	"github.com/wcatron/query-projects/internal/projects"
 )
 
-// WriteJSONOutput creates a .json file summarizing the results
-func WriteJSONOutput(rootDirectory string, scriptPath string, results []Result) error {
-	filename := filepath.Base(scriptPath)
-	resultsFilenameForScript := strings.TrimSuffix(filename, ".ts")
+// jsonOutput converts the rows of a result into JSON: objects keyed by column for
+// csv scripts and the emitted values for json scripts.
+func jsonOutput(info ScriptInfo, r Result) any {
+	if info.Output == "csv" {
+		objects := make([]map[string]any, len(r.Rows))
+		for i, row := range r.Rows {
+			objects[i] = make(map[string]any, len(row))
+			for j, value := range row {
+				if j < len(info.Columns) {
+					objects[i][info.Columns[j]] = value
+				}
+			}
+		}
+		return objects
+	}
+	if len(r.Rows) == 1 {
+		return r.Rows[0][0]
+	}
+	values := make([]any, len(r.Rows))
+	for i, row := range r.Rows {
+		values[i] = row[0]
+	}
+	return values
+}
 
-	// Transform []Result → []map[string]any
-	var payload []map[string]any
+// JSONEntry is the JSON object written for one project's result, also used by
+// --stdout and the serve API
+func JSONEntry(info ScriptInfo, r Result) map[string]any {
+	entry := map[string]any{
+		"Project Path": r.ProjectPath,
+		"Status":       r.Status,
+	}
 
-	for _, r := range results {
-		entry := map[string]any{
-			"Project Path": r.ProjectPath,
-			"Status":       r.Status,
-		}
+	if r.Package != "" {
+		entry["Package"] = r.Package
+	}
 
-		// Parse StdoutText as JSON; fall back to raw string on error.
-		var out any
-		if err := json.Unmarshal([]byte(r.StdoutText), &out); err == nil {
-			entry["Output"] = out
-		} else {
-			entry["StdOut"] = r.StdoutText
-		}
+	// Text scripts keep their raw output
+	if info.Output == "csv" || info.Output == "json" {
+		entry["Output"] = jsonOutput(info, r)
+	} else {
+		entry["StdOut"] = r.OutputText()
+	}
 
-		if strings.TrimSpace(r.StderrText) != "" {
-			entry["StdErr"] = r.StderrText
-		}
+	if len(r.Warnings) > 0 {
+		entry["Warnings"] = r.Warnings
+	}
+
+	if r.Ref != "" {
+		entry["Ref"] = r.Ref
+	}
 
-		payload = append(payload, entry)
+	if strings.TrimSpace(r.StderrText) != "" {
+		entry["StdErr"] = r.StderrText
+	}
+
+	return entry
+}
+
+// WriteJSONOutput creates a .json file summarizing the results at base.json
+func WriteJSONOutput(log io.Writer, base string, info ScriptInfo, results []Result) error {
+
+	// Transform []Result → []map[string]any
+	var payload []map[string]any
+
+	for _, r := range results {
+		payload = append(payload, JSONEntry(info, r))
 	}
 
 	// Encode & write to disk
@@ -45,11 +83,11 @@ func WriteJSONOutput(rootDirectory string, scriptPath string, results []Result)
 		return fmt.Errorf("marshal results: %w", err)
 	}
 
-	tableFilePath := filepath.Join(rootDirectory, projects.ResultsFolder, resultsFilenameForScript+".json")
+	tableFilePath := base + ".json"
 	if err := os.WriteFile(tableFilePath, data, 0o644); err != nil {
 		return fmt.Errorf("write results file: %w", err)
 	}
 
-	fmt.Printf("Results written to %s\n", CleanPath(tableFilePath))
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
 	return nil
 }
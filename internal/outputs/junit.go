@@ -0,0 +1,85 @@
+package outputs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// createJUnitReport makes each project a test case of the script's suite. Any
+// status other than Success, or a failed expectation, is a failure.
+func createJUnitReport(info ScriptInfo, results []Result) junitTestSuites {
+	suiteName := strings.TrimSuffix(filepath.Base(info.Path), filepath.Ext(info.Path))
+	suite := junitTestSuite{Name: suiteName, Tests: len(results)}
+
+	for _, r := range results {
+		testCase := junitTestCase{
+			ClassName: suiteName,
+			Name:      r.Label(),
+			SystemOut: r.OutputText(),
+			SystemErr: r.StderrText,
+		}
+		if r.Status != "Success" {
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: r.Status, Text: r.StderrText}
+		} else if len(r.Violations) > 0 {
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: "Expectation failed", Text: strings.Join(r.Violations, "\n")}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	return junitTestSuites{
+		Name:     "query-projects",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+}
+
+// WriteJUnitOutput creates a JUnit XML file with one test case per project
+func WriteJUnitOutput(log io.Writer, base string, info ScriptInfo, results []Result) error {
+	data, err := xml.MarshalIndent(createJUnitReport(info, results), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal junit: %w", err)
+	}
+
+	tableFilePath := base + ".junit.xml"
+	if err := os.WriteFile(tableFilePath, append([]byte(xml.Header), data...), 0o644); err != nil {
+		return fmt.Errorf("write results file: %w", err)
+	}
+
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
+	return nil
+}
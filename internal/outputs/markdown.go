@@ -2,12 +2,11 @@ package outputs
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/glamour"
-	"github.com/wcatron/query-projects/internal/projects"
 )
 
 // createMarkdownString creates a markdown table string from results
@@ -18,12 +17,17 @@ func createMarkdownString(results []Result) strings.Builder {
 	sb.WriteString("| " + strings.Repeat("--- | ", len(headers)) + "\n")
 
 	for _, r := range results {
-		lines := strings.Split(r.StdoutText, "\n")
-		for _, line := range lines {
+		rows := r.Rows
+		if len(rows) == 0 {
+			rows = []Row{{""}}
+		}
+		for _, values := range rows {
+			// Line breaks would end the table row early
+			output := strings.ReplaceAll(strings.Join(values.Cells(), ","), "\n", "<br>")
 			row := []string{
-				r.ProjectPath,
+				r.Label(),
 				r.Status,
-				line,
+				output,
 			}
 			sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
 		}
@@ -33,31 +37,29 @@ func createMarkdownString(results []Result) strings.Builder {
 }
 
 // PrintToConsole renders the results in markdown format to the console using Glamour
-func PrintToConsole(results []Result) {
+func PrintToConsole(w io.Writer, results []Result) {
 	var sb strings.Builder = createMarkdownString(results)
 
 	// Render the markdown table using Glamour
 	out, err := glamour.Render(sb.String(), "dark")
 	if err != nil {
-		fmt.Println("Error rendering markdown:", err)
+		fmt.Fprintln(w, "Error rendering markdown:", err)
 		return
 	}
-	fmt.Print(out)
+	fmt.Fprint(w, out)
 }
 
 // WriteTable creates a .md table summarizing the results with their output
-func WriteTable(rootDirectory string, scriptPath string, results []Result) error {
-	filename := filepath.Base(scriptPath)
-	resultsFilenameForScript := strings.TrimSuffix(filename, ".ts")
+func WriteTable(log io.Writer, base string, results []Result) error {
 
 	// Build the table lines
 	var sb strings.Builder = createMarkdownString(results)
 
 	// Write to file: e.g. results/foo.md
-	tableFilePath := filepath.Join(rootDirectory, projects.ResultsFolder, resultsFilenameForScript+".md")
+	tableFilePath := base + ".md"
 	if err := os.WriteFile(tableFilePath, []byte(sb.String()), 0644); err != nil {
 		return err
 	}
-	fmt.Printf("Results written to %s\n", CleanPath(tableFilePath))
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
 	return nil
 }
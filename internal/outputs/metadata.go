@@ -0,0 +1,40 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunMetadata records the inputs of a run so its results can be regenerated.
+// LockHash is the sha256 of projects.lock at the time of the run.
+type RunMetadata struct {
+	Script   string    `json:"script"`
+	Args     []string  `json:"args,omitempty"`
+	Ref      string    `json:"ref,omitempty"`
+	LockHash string    `json:"lockHash,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+}
+
+// WriteRunMetadata writes base.meta.json next to the results of a script
+func WriteRunMetadata(log io.Writer, base string, meta RunMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal run metadata: %w", err)
+	}
+
+	metaFilePath := base + ".meta.json"
+	if err := os.MkdirAll(filepath.Dir(metaFilePath), 0o755); err != nil {
+		return fmt.Errorf("write run metadata: %w", err)
+	}
+	if err := os.WriteFile(metaFilePath, data, 0o644); err != nil {
+		return fmt.Errorf("write run metadata: %w", err)
+	}
+
+	fmt.Fprintf(log, "Run metadata written to %s\n", CleanPath(metaFilePath))
+	return nil
+}
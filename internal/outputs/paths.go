@@ -0,0 +1,67 @@
+package outputs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._=-]+`)
+
+// ScriptName names a script's results: its path below the scripts folder
+// without .ts, so scripts/sub/deps.ts is sub-deps and doesn't overwrite the
+// results of scripts/deps.ts.
+func ScriptName(scriptPath string) string {
+	name := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(scriptPath)), ".ts")
+	name = strings.TrimPrefix(name, projects.ScriptsFolder+"/")
+	if strings.HasPrefix(name, "../") || filepath.IsAbs(scriptPath) {
+		name = filepath.Base(name)
+	}
+	return strings.ReplaceAll(name, "/", "-")
+}
+
+// ArgsName joins args into something safe to use in a file name.
+func ArgsName(args []string) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		if part := strings.Trim(unsafeNameChars.ReplaceAllString(arg, "_"), "_-"); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// ResultsBase returns the path results are written to, without an extension.
+// By default it is results/<script>, followed by the script's args so runs
+// with different args don't overwrite each other. A pattern, relative to the
+// root directory, may use {script}, {date} and {args}. A pattern ending in /
+// is a directory for the default name.
+func ResultsBase(rootDirectory string, pattern string, scriptPath string, args []string, started time.Time) string {
+	defaultName := ScriptName(scriptPath)
+	if argsName := ArgsName(args); argsName != "" {
+		defaultName += "-" + argsName
+	}
+	if pattern == "" {
+		return filepath.Join(rootDirectory, projects.ResultsFolder, defaultName)
+	}
+
+	base := strings.NewReplacer(
+		"{script}", ScriptName(scriptPath),
+		"{date}", started.Format("2006-01-02"),
+		"{args}", ArgsName(args),
+	).Replace(pattern)
+	if strings.HasSuffix(base, "/") || strings.HasSuffix(base, string(os.PathSeparator)) {
+		base = filepath.Join(base, defaultName)
+	} else {
+		// {script}-{args} without args shouldn't leave a trailing -
+		base = strings.TrimRight(base, "-_.")
+	}
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(rootDirectory, base)
+	}
+	return filepath.Clean(base)
+}
@@ -0,0 +1,32 @@
+package outputs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResultsBase(t *testing.T) {
+	started := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		pattern string
+		script  string
+		args    []string
+		want    string
+	}{
+		{"", "scripts/deps.ts", nil, "/root/results/deps"},
+		{"", "scripts/sub/deps.ts", nil, "/root/results/sub-deps"},
+		{"", "scripts/deps.ts", []string{"--package", "@types/node"}, "/root/results/deps-package-types_node"},
+		{"reports/{date}/{script}{args}", "scripts/deps.ts", nil, "/root/reports/2025-03-14/deps"},
+		{"reports/{script}-{args}", "scripts/deps.ts", []string{"react"}, "/root/reports/deps-react"},
+		{"reports/{script}-{args}", "scripts/deps.ts", nil, "/root/reports/deps"},
+		{"archive/", "scripts/deps.ts", []string{"react"}, "/root/archive/deps-react"},
+		{"/tmp/out", "scripts/deps.ts", nil, "/tmp/out"},
+	}
+	for _, tt := range tests {
+		got := ResultsBase("/root", tt.pattern, tt.script, tt.args, started)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("ResultsBase(%q, %q, %v) = %q, expected %q", tt.pattern, tt.script, tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package outputs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RecordsFDEnv tells scripts which file descriptor to write records to.
+const RecordsFDEnv = "QUERY_PROJECTS_RECORDS_FD"
+
+// RecordsFileEnv tells scripts which file to append records to on Windows,
+// where they can't be passed a file descriptor.
+const RecordsFileEnv = "QUERY_PROJECTS_RECORDS_FILE"
+
+// Record is one NDJSON message sent by a script over the records channel.
+// Type is row, finding, warning or progress. Rows and findings carry their
+// value in Data and the others a Message.
+type Record struct {
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// Records is everything a script sent over the records channel.
+type Records struct {
+	Rows     []Row
+	Findings []Finding
+	Warnings []string
+	Errors   []error
+	// Received is true once a row record was read, so stdout isn't parsed for
+	// rows. Scripts that only send warnings or progress still print their rows.
+	Received bool
+}
+
+// ReadRecords reads NDJSON records until r is closed. Progress messages are
+// passed to onProgress as they arrive.
+func ReadRecords(info ScriptInfo, r io.Reader, onProgress func(string)) Records {
+	var records Records
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			records.Errors = append(records.Errors, fmt.Errorf("record %d: %w", line, err))
+			continue
+		}
+
+		switch record.Type {
+		case "row":
+			records.Received = true
+			row, err := rowFromRecord(info, record.Data)
+			if err != nil {
+				records.Errors = append(records.Errors, fmt.Errorf("record %d: %w", line, err))
+				continue
+			}
+			records.Rows = append(records.Rows, row)
+		case "finding":
+			var finding Finding
+			if err := json.Unmarshal(record.Data, &finding); err != nil || finding.Message == "" {
+				records.Errors = append(records.Errors, fmt.Errorf("record %d: finding requires a message", line))
+				continue
+			}
+			records.Findings = append(records.Findings, finding)
+		case "warning":
+			records.Warnings = append(records.Warnings, record.Message)
+		case "progress":
+			if onProgress != nil {
+				onProgress(record.Message)
+			}
+		default:
+			records.Errors = append(records.Errors, fmt.Errorf("record %d: unknown type %q", line, record.Type))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		records.Errors = append(records.Errors, err)
+	}
+	return records
+}
+
+func rowFromRecord(info ScriptInfo, data json.RawMessage) (Row, error) {
+	if info.Output == "csv" {
+		return csvRowFromJSON(info.Columns, data)
+	}
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return Row{value}, nil
+}
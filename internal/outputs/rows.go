@@ -0,0 +1,206 @@
+package outputs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Row is one typed record from a script. Scripts with csv output have one value
+// per column, json and text scripts have a single value.
+type Row []any
+
+// Cells formats every value in the row for text outputs
+func (row Row) Cells() []string {
+	cells := make([]string, len(row))
+	for i, v := range row {
+		cells[i] = FormatValue(v)
+	}
+	return cells
+}
+
+// FormatValue formats a row value for text outputs. Strings are written as is
+// and everything else as JSON.
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// ParseRows splits a script's stdout into rows. Scripts with csv output are
+// parsed as RFC 4180 CSV, or JSON lines when their encoding is jsonl, and each
+// row is checked against the script's columns. Scripts with json output produce
+// a row per JSON value and text scripts a row per line. Rows that can't be
+// parsed are returned as errors and skipped.
+func ParseRows(info ScriptInfo, stdout string) ([]Row, []error) {
+	if stdout == "" {
+		return nil, nil
+	}
+	switch {
+	case info.Output == "csv" && info.Encoding == "jsonl":
+		return parseJSONLRows(info.Columns, stdout)
+	case info.Output == "csv":
+		return parseCSVRows(info.Columns, stdout)
+	case info.Output == "json":
+		return parseJSONRows(stdout), nil
+	default:
+		var rows []Row
+		for _, line := range strings.Split(stdout, "\n") {
+			rows = append(rows, Row{line})
+		}
+		return rows, nil
+	}
+}
+
+func parseCSVRows(columns []string, stdout string) ([]Row, []error) {
+	r := csv.NewReader(strings.NewReader(stdout))
+	r.FieldsPerRecord = -1
+
+	var rows []Row
+	var errs []error
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return rows, append(errs, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if len(columns) > 0 && len(record) != len(columns) {
+			line, _ := r.FieldPos(0)
+			errs = append(errs, fmt.Errorf("record on line %d: expected %d fields, got %d", line, len(columns), len(record)))
+			continue
+		}
+		row := make(Row, len(record))
+		for i, v := range record {
+			row[i] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, errs
+}
+
+func parseJSONLRows(columns []string, stdout string) ([]Row, []error) {
+	var rows []Row
+	var errs []error
+	for i, line := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		row, err := csvRowFromJSON(columns, []byte(line))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("record on line %d: %w", i+1, err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, errs
+}
+
+// parseJSONRows decodes each JSON value in stdout. Scripts that print something
+// other than JSON, such as an error message, keep their output as a string.
+func parseJSONRows(stdout string) []Row {
+	var rows []Row
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	decoder.UseNumber()
+	for {
+		var value any
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return rows
+		} else if err != nil {
+			return []Row{{stdout}}
+		}
+		rows = append(rows, Row{value})
+	}
+}
+
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+// csvRowFromJSON converts a JSON array, or an object keyed by column, into a
+// row with one value per column.
+func csvRowFromJSON(columns []string, data []byte) (Row, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case []any:
+		if len(columns) > 0 && len(v) != len(columns) {
+			return nil, fmt.Errorf("expected %d fields, got %d", len(columns), len(v))
+		}
+		return Row(v), nil
+	case map[string]any:
+		for key := range v {
+			if !slices.Contains(columns, key) {
+				return nil, fmt.Errorf("unknown column %q", key)
+			}
+		}
+		row := make(Row, len(columns))
+		for i, column := range columns {
+			row[i] = v[column]
+		}
+		return row, nil
+	default:
+		return nil, errors.New("expected a JSON array or object")
+	}
+}
+
+// FieldValue looks up a column by name in a csv row, or a dot separated field
+// in a json row.
+func FieldValue(info ScriptInfo, row Row, field string) (string, bool) {
+	if info.Output == "csv" {
+		for i, column := range info.Columns {
+			if column == field && i < len(row) {
+				return FormatValue(row[i]), true
+			}
+		}
+		return "", false
+	}
+	if len(row) == 0 {
+		return "", false
+	}
+
+	current := row[0]
+	if s, isString := current.(string); isString {
+		// Rows parsed from stdout that weren't JSON are kept as strings
+		if err := json.Unmarshal([]byte(s), &current); err != nil {
+			return "", false
+		}
+	}
+	for _, key := range strings.Split(field, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if current, ok = m[key]; !ok {
+			return "", false
+		}
+	}
+	return FormatValue(current), true
+}
@@ -0,0 +1,124 @@
+package outputs
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRows_CSV(t *testing.T) {
+	info := ScriptInfo{Output: "csv", Columns: []string{"name", "note"}}
+	stdout := "\"a,b\",\"say \"\"hi\"\"\"\n\"multi\nline\",plain\ntoo,many,fields\nbad\"quote,x\nlast,row"
+
+	rows, errs := ParseRows(info, stdout)
+	expected := []Row{
+		{"a,b", `say "hi"`},
+		{"multi\nline", "plain"},
+		{"last", "row"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %q, got %q", expected, rows)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 malformed rows, got %v", errs)
+	}
+}
+
+func TestParseRows_JSONL(t *testing.T) {
+	info := ScriptInfo{Output: "csv", Columns: []string{"name", "version"}, Encoding: "jsonl"}
+	stdout := `["typescript", "5.4.0"]
+{"version": 18, "name": "node"}
+["missing"]
+{"name": "x", "extra": true}`
+
+	rows, errs := ParseRows(info, stdout)
+	expected := []Row{{"typescript", "5.4.0"}, {"node", json.Number("18")}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, got %v", expected, rows)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 malformed rows, got %v", errs)
+	}
+}
+
+func TestParseRows_JSON(t *testing.T) {
+	rows, _ := ParseRows(ScriptInfo{Output: "json"}, "{\n  \"a\": 1\n}\n{\"b\": [true]}")
+	expected := []Row{
+		{map[string]any{"a": json.Number("1")}},
+		{map[string]any{"b": []any{true}}},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, got %v", expected, rows)
+	}
+
+	rows, _ = ParseRows(ScriptInfo{Output: "json"}, "Error")
+	if !reflect.DeepEqual(rows, []Row{{"Error"}}) {
+		t.Errorf("Expected non-JSON output to be kept as a string, got %v", rows)
+	}
+}
+
+func TestParseRows_Text(t *testing.T) {
+	rows, errs := ParseRows(ScriptInfo{Output: "text"}, "a,b\nc")
+	expected := []Row{{"a,b"}, {"c"}}
+	if !reflect.DeepEqual(rows, expected) || errs != nil {
+		t.Errorf("Expected %q, got %q, %v", expected, rows, errs)
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	info := ScriptInfo{Output: "csv", Columns: []string{"name", "version"}}
+	input := `{"type":"progress","message":"reading package.json"}
+{"type":"row","data":["typescript","5.4.0"]}
+{"type":"row","data":{"name":"node","version":20}}
+{"type":"warning","message":"no lockfile"}
+{"type":"row","data":["short"]}
+not json
+`
+	var progress []string
+	records := ReadRecords(info, strings.NewReader(input), func(message string) {
+		progress = append(progress, message)
+	})
+
+	expected := []Row{{"typescript", "5.4.0"}, {"node", json.Number("20")}}
+	if !reflect.DeepEqual(records.Rows, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, records.Rows)
+	}
+	if !reflect.DeepEqual(records.Warnings, []string{"no lockfile"}) {
+		t.Errorf("Expected a warning, got %v", records.Warnings)
+	}
+	if !reflect.DeepEqual(progress, []string{"reading package.json"}) {
+		t.Errorf("Expected progress to be reported, got %v", progress)
+	}
+	if len(records.Errors) != 2 || !records.Received {
+		t.Errorf("Expected 2 errors, got %v", records.Errors)
+	}
+}
+
+func TestReadRecords_WithoutRows(t *testing.T) {
+	input := `{"type":"progress","message":"reading package.json"}
+{"type":"warning","message":"no lockfile"}
+`
+	records := ReadRecords(ScriptInfo{Output: "text"}, strings.NewReader(input), nil)
+	if records.Received {
+		t.Errorf("Expected stdout to be used when no rows were sent")
+	}
+	if !reflect.DeepEqual(records.Warnings, []string{"no lockfile"}) {
+		t.Errorf("Expected a warning, got %v", records.Warnings)
+	}
+}
+
+func TestReadRecords_Empty(t *testing.T) {
+	records := ReadRecords(ScriptInfo{Output: "text"}, strings.NewReader(""), nil)
+	if records.Received {
+		t.Errorf("Expected no records to be received")
+	}
+}
+
+func TestResult_OutputText(t *testing.T) {
+	r := Result{Rows: []Row{{"react", "18.2.0"}, {"a, b", `say "hi"`}, {"plain, text line"}}}
+	want := "react,18.2.0\n\"a, b\",\"say \"\"hi\"\"\"\nplain, text line"
+	if got := r.OutputText(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
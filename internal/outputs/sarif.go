@@ -0,0 +1,158 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifLevel maps a finding level onto the levels SARIF allows
+func sarifLevel(level string) string {
+	switch level {
+	case "error", "warning", "note", "none":
+		return level
+	default:
+		return "warning"
+	}
+}
+
+// createSARIFLog converts findings into SARIF results. Projects whose script
+// didn't succeed and reported no findings get an error result for the project
+// so the failure isn't lost.
+func createSARIFLog(info ScriptInfo, results []Result) sarifLog {
+	scriptRule := strings.TrimSuffix(filepath.Base(info.Path), filepath.Ext(info.Path))
+	ruleIDs := map[string]bool{}
+	var rules []sarifRule
+	addRule := func(id string) {
+		if !ruleIDs[id] {
+			ruleIDs[id] = true
+			rules = append(rules, sarifRule{ID: id})
+		}
+	}
+
+	sarifResults := []sarifResult{}
+	for _, r := range results {
+		projectURI := path.Clean(filepath.ToSlash(r.ProjectPath))
+		for _, f := range r.Findings {
+			ruleID := f.RuleID
+			if ruleID == "" {
+				ruleID = scriptRule
+			}
+			addRule(ruleID)
+
+			location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: path.Join(projectURI, filepath.ToSlash(r.Dir), filepath.ToSlash(f.File))}}
+			if f.Line > 0 {
+				location.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+			}
+			sarifResults = append(sarifResults, sarifResult{
+				RuleID:    ruleID,
+				Level:     sarifLevel(f.Level),
+				Message:   sarifMessage{Text: f.Message},
+				Locations: []sarifLocation{{PhysicalLocation: location}},
+			})
+		}
+
+		if len(r.Findings) == 0 && r.Status != "Success" {
+			addRule(scriptRule)
+			message := r.Status
+			if r.StderrText != "" {
+				message += ": " + r.StderrText
+			}
+			sarifResults = append(sarifResults, sarifResult{
+				RuleID:    scriptRule,
+				Level:     "error",
+				Message:   sarifMessage{Text: message},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: projectURI}}}},
+			})
+		}
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "query-projects",
+				InformationURI: "https://github.com/wcatron/query-projects",
+				Rules:          rules,
+			}},
+			Results: sarifResults,
+		}},
+	}
+}
+
+// WriteSARIFOutput creates a .sarif file with the findings reported by a script
+func WriteSARIFOutput(log io.Writer, base string, info ScriptInfo, results []Result) error {
+	data, err := json.MarshalIndent(createSARIFLog(info, results), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal sarif: %w", err)
+	}
+
+	tableFilePath := base + ".sarif"
+	if err := os.WriteFile(tableFilePath, data, 0o644); err != nil {
+		return fmt.Errorf("write results file: %w", err)
+	}
+
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(tableFilePath))
+	return nil
+}
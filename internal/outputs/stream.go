@@ -0,0 +1,44 @@
+package outputs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// StreamFormats are the formats WriteStream supports.
+var StreamFormats = []string{"json", "ndjson", "csv", "tsv"}
+
+// WriteStream writes results to w with no decoration so they can be piped to
+// other tools. json and ndjson use the objects of the json output with the
+// script added, csv and tsv the rows of the csv output.
+func WriteStream(w io.Writer, format string, info ScriptInfo, results []Result) error {
+	switch format {
+	case "json", "ndjson":
+		entries := make([]map[string]any, len(results))
+		for i, r := range results {
+			entries[i] = JSONEntry(info, r)
+			entries[i]["Script"] = info.Path
+		}
+		encoder := json.NewEncoder(w)
+		if format == "json" {
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(entries)
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv", "tsv":
+		writer := csv.NewWriter(w)
+		if format == "tsv" {
+			writer.Comma = '\t'
+		}
+		return writeCSVRows(writer, info, results)
+	default:
+		return fmt.Errorf("unsupported format %q, expected one of json, ndjson, csv or tsv", format)
+	}
+}
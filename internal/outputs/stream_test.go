@@ -0,0 +1,54 @@
+package outputs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteStream(t *testing.T) {
+	info := ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	results := []Result{
+		{ProjectPath: "./projects/a", Status: "Success", Rows: []Row{{"react", "18.2.0"}, {"a\tb", "1"}}},
+		{ProjectPath: "./projects/b", Status: "Failed (exit code 1)", StderrText: "boom"},
+	}
+
+	tests := map[string]string{
+		"ndjson": `{"Output":[{"name":"react","version":"18.2.0"},{"name":"a\tb","version":"1"}],"Project Path":"./projects/a","Script":"scripts/deps.ts","Status":"Success"}
+{"Output":[],"Project Path":"./projects/b","Script":"scripts/deps.ts","Status":"Failed (exit code 1)","StdErr":"boom"}
+`,
+		"tsv": "Project Path\tStatus\tname\tversion\n./projects/a\tSuccess\treact\t18.2.0\n./projects/a\tSuccess\t\"a\tb\"\t1\n./projects/b\tFailed (exit code 1)\t\t\n",
+	}
+	for format, want := range tests {
+		var sb strings.Builder
+		if err := WriteStream(&sb, format, info, results); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != want {
+			t.Errorf("Expected %s\n%q\ngot\n%q", format, want, sb.String())
+		}
+	}
+
+	// Workspace packages keep their project's path and add a Package column
+	packages := []Result{
+		{ProjectPath: "./projects/mono", Package: "web", Status: "Success", Rows: []Row{{"react", "18.2.0"}}},
+		{ProjectPath: "./projects/a", Status: "Success"},
+	}
+	for format, want := range map[string]string{
+		"ndjson": `{"Output":[{"name":"react","version":"18.2.0"}],"Package":"web","Project Path":"./projects/mono","Script":"scripts/deps.ts","Status":"Success"}
+{"Output":[],"Project Path":"./projects/a","Script":"scripts/deps.ts","Status":"Success"}
+`,
+		"csv": "Project Path,Package,Status,name,version\n./projects/mono,web,Success,react,18.2.0\n./projects/a,,Success,,\n",
+	} {
+		var sb strings.Builder
+		if err := WriteStream(&sb, format, info, packages); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != want {
+			t.Errorf("Expected %s\n%q\ngot\n%q", format, want, sb.String())
+		}
+	}
+
+	if err := WriteStream(&strings.Builder{}, "xml", info, results); err == nil {
+		t.Errorf("Expected xml to be unsupported")
+	}
+}
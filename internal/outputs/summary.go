@@ -0,0 +1,111 @@
+package outputs
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+)
+
+//go:embed templates/summary.html.tmpl
+var summaryTemplates embed.FS
+
+var summaryHTMLTemplate = template.Must(template.ParseFS(summaryTemplates, "templates/summary.html.tmpl"))
+
+// Summary is a table computed across projects, such as grouped counts or a
+// pivot, rather than one row per project.
+type Summary struct {
+	Title   string
+	Columns []string
+	Rows    []Row
+}
+
+func (s Summary) markdown() string {
+	var sb strings.Builder
+	sb.WriteString("| " + strings.Join(s.Columns, " | ") + " |\n")
+	sb.WriteString("| " + strings.Repeat("--- | ", len(s.Columns)) + "\n")
+	for _, row := range s.Rows {
+		cells := row.Cells()
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(cell, "\n", "<br>")
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return sb.String()
+}
+
+// PrintSummary renders the summary as a markdown table in the console
+func PrintSummary(w io.Writer, s Summary) {
+	out, err := glamour.Render(s.markdown(), "dark")
+	if err != nil {
+		fmt.Fprintln(w, "Error rendering markdown:", err)
+		return
+	}
+	fmt.Fprint(w, out)
+}
+
+// WriteSummary writes the summary to base.<format>. md, csv, json and html are
+// supported.
+func WriteSummary(log io.Writer, base string, format string, s Summary) error {
+	var data []byte
+	switch format {
+	case "md":
+		data = []byte(s.markdown())
+	case "csv":
+		var sb strings.Builder
+		writer := csv.NewWriter(&sb)
+		writer.Write(s.Columns)
+		for _, row := range s.Rows {
+			writer.Write(row.Cells())
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		data = []byte(sb.String())
+	case "json":
+		objects := make([]map[string]any, len(s.Rows))
+		for i, row := range s.Rows {
+			objects[i] = make(map[string]any, len(s.Columns))
+			for j, column := range s.Columns {
+				if j < len(row) {
+					objects[i][column] = row[j]
+				}
+			}
+		}
+		var err error
+		if data, err = json.MarshalIndent(objects, "", "  "); err != nil {
+			return err
+		}
+	case "html":
+		var sb strings.Builder
+		rows := make([][]string, len(s.Rows))
+		for i, row := range s.Rows {
+			rows[i] = row.Cells()
+		}
+		report := struct {
+			Title   string
+			Columns []string
+			Rows    [][]string
+		}{s.Title, s.Columns, rows}
+		if err := summaryHTMLTemplate.Execute(&sb, report); err != nil {
+			return err
+		}
+		data = []byte(sb.String())
+	default:
+		return fmt.Errorf("summaries can't be written as %s", format)
+	}
+
+	filePath := base + "." + format
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("write results file: %w", err)
+	}
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(filePath))
+	return nil
+}
@@ -0,0 +1,185 @@
+package outputs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+// templateResult is a result with its project for use in templates.
+type templateResult struct {
+	Result
+	Project projects.Project
+}
+
+// templateGroup is a group of results sharing a key, from groupBy.
+type templateGroup struct {
+	Key     string
+	Results []templateResult
+}
+
+// templateCount is the number of results with a key, from countBy.
+type templateCount struct {
+	Key   string
+	Count int
+}
+
+type templateData struct {
+	Script    ScriptInfo
+	Results   []templateResult
+	Generated time.Time
+}
+
+// values returns what a result holds for a key: status, output, topic,
+// metadata.<field>, or each row's value for a csv column / json field.
+func (r templateResult) values(info ScriptInfo, key string) []string {
+	switch {
+	case key == "status":
+		return []string{r.Status}
+	case key == "output":
+		return []string{strings.TrimSpace(r.OutputText())}
+	case key == "topic" || key == "topics":
+		return r.Project.Topics
+	case strings.HasPrefix(key, "metadata."):
+		return []string{r.Project.MetadataValue(strings.TrimPrefix(key, "metadata."))}
+	}
+	var values []string
+	for _, row := range r.Rows {
+		if value, ok := FieldValue(info, row, key); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// first returns the first value for a key, or an empty string.
+func (r templateResult) first(info ScriptInfo, key string) string {
+	values := r.values(info, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// templateFuncs are the helpers available to templates, see README.md.
+func templateFuncs(info ScriptInfo) template.FuncMap {
+	return template.FuncMap{
+		"field": func(key string, r templateResult) string {
+			return r.first(info, key)
+		},
+		"fields": func(key string, r templateResult) []string {
+			return r.values(info, key)
+		},
+		"meta": func(field string, r templateResult) string {
+			return r.Project.MetadataValue(field)
+		},
+		"groupBy": func(key string, results []templateResult) []templateGroup {
+			var groups []templateGroup
+			for _, r := range results {
+				for _, value := range r.values(info, key) {
+					i := slices.IndexFunc(groups, func(g templateGroup) bool { return g.Key == value })
+					if i == -1 {
+						groups = append(groups, templateGroup{Key: value})
+						i = len(groups) - 1
+					}
+					groups[i].Results = append(groups[i].Results, r)
+				}
+			}
+			sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+			return groups
+		},
+		"countBy": func(key string, results []templateResult) []templateCount {
+			counts := map[string]int{}
+			for _, r := range results {
+				for _, value := range r.values(info, key) {
+					counts[value]++
+				}
+			}
+			var out []templateCount
+			for k, c := range counts {
+				out = append(out, templateCount{Key: k, Count: c})
+			}
+			sort.Slice(out, func(i, j int) bool {
+				if out[i].Count != out[j].Count {
+					return out[i].Count > out[j].Count
+				}
+				return out[i].Key < out[j].Key
+			})
+			return out
+		},
+		"sortBy": func(key string, results []templateResult) []templateResult {
+			sorted := slices.Clone(results)
+			sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].first(info, key) < sorted[j].first(info, key) })
+			return sorted
+		},
+		"where": func(key string, value string, results []templateResult) []templateResult {
+			return slices.DeleteFunc(slices.Clone(results), func(r templateResult) bool {
+				return !slices.Contains(r.values(info, key), value)
+			})
+		},
+		"count": func(results []templateResult) int { return len(results) },
+		"cells": func(row Row) []string { return row.Cells() },
+		"join":  func(sep string, values []string) string { return strings.Join(values, sep) },
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+	}
+}
+
+// RenderTemplate renders results through a text/template file.
+func RenderTemplate(templatePath string, info ScriptInfo, results []Result, projectsList []projects.Project) (string, error) {
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(templateFuncs(info)).ParseFiles(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+
+	data := templateData{Script: info, Generated: time.Now()}
+	for _, r := range results {
+		tr := templateResult{Result: r}
+		if p := projects.FindProject(projectsList, r.ProjectPath); p != nil {
+			tr.Project = *p
+		}
+		data.Results = append(data.Results, tr)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// WriteTemplateOutput renders results through a template and writes them to
+// the path from templateOutputPath.
+func WriteTemplateOutput(log io.Writer, base string, templatePath string, info ScriptInfo, results []Result, projectsList []projects.Project) error {
+	out, err := RenderTemplate(templatePath, info, results, projectsList)
+	if err != nil {
+		return err
+	}
+
+	filePath := templateOutputPath(base, templatePath)
+	if err := os.WriteFile(filePath, []byte(out), 0o644); err != nil {
+		return fmt.Errorf("write results file: %w", err)
+	}
+	fmt.Fprintf(log, "Results written to %s\n", CleanPath(filePath))
+	return nil
+}
+
+// templateOutputPath is base.<template name without .tmpl>, e.g.
+// results/deps.wiki.md for wiki.md.tmpl. Templates without an extension of
+// their own, such as report.tmpl, are written as text, results/deps.report.txt.
+func templateOutputPath(base string, templatePath string) string {
+	templateName := strings.TrimSuffix(filepath.Base(templatePath), ".tmpl")
+	if filepath.Ext(templateName) == "" {
+		templateName += ".txt"
+	}
+	return base + "." + templateName
+}
@@ -0,0 +1,59 @@
+package outputs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	info := ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	results := []Result{
+		{ProjectPath: "projects/b", Status: "Success", Rows: []Row{{"react", "17.0.2"}}},
+		{ProjectPath: "projects/a", Status: "Success", Rows: []Row{{"react", "18.2.0"}, {"vue", "3.4.0"}}},
+		{ProjectPath: "projects/c", Status: "Failed (exit code 1)"},
+	}
+	projectsList := []projects.Project{
+		{Path: "projects/a", Metadata: map[string]any{"language": "TypeScript"}},
+		{Path: "projects/b", Metadata: map[string]any{"language": "JavaScript"}},
+	}
+
+	templatePath := filepath.Join(t.TempDir(), "wiki.md.tmpl")
+	tmpl := `# {{.Script.Path}}
+{{range groupBy "status" .Results}}## {{.Key}} ({{count .Results}})
+{{range sortBy "version" .Results}}- {{.Label}} {{meta "language" .}}: {{join ", " (fields "name" .)}}
+{{end}}{{end}}{{range countBy "name" .Results}}{{.Key}}={{.Count}} {{end}}`
+	if err := os.WriteFile(templatePath, []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := RenderTemplate(templatePath, info, results, projectsList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# scripts/deps.ts
+## Failed (exit code 1) (1)
+- projects/c : 
+## Success (2)
+- projects/b JavaScript: react
+- projects/a TypeScript: react, vue
+react=2 vue=1 `
+	if out != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, out)
+	}
+}
+
+func TestTemplateOutputPath(t *testing.T) {
+	tests := map[string]string{
+		"templates/wiki.md.tmpl": "results/deps.wiki.md",
+		"report.html.tmpl":       "results/deps.report.html",
+		"templates/report.tmpl":  "results/deps.report.txt",
+	}
+	for templatePath, want := range tests {
+		if got := templateOutputPath("results/deps", templatePath); got != want {
+			t.Errorf("templateOutputPath(%q) = %q, expected %q", templatePath, got, want)
+		}
+	}
+}
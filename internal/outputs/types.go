@@ -1,26 +1,79 @@
 package outputs
 
 import (
+	"encoding/csv"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Result represents the output of running a script on a project
 type Result struct {
 	ProjectPath string
+	Package     string // Workspace package when the project was expanded
+	Ref         string // Ref the script ran against when not the main checkout
+	Dir         string // Directory the script ran in, relative to the project
 	Status      string
 	StdoutText  string
 	StderrText  string
+	Rows        []Row     // Rows sent over the records channel or parsed from stdout
+	RowErrors   []error   // Rows that couldn't be parsed
+	Warnings    []string  // Warnings sent over the records channel
+	Findings    []Finding // Findings sent over the records channel
+	Violations  []string  // Expectations the result didn't meet
 	Index       int
 }
 
+// Finding is a problem a script found at a location in a project. File is
+// relative to the directory the script ran in, Result.Dir, which is the
+// project unless a workspace package or run.dir says otherwise.
+type Finding struct {
+	RuleID  string `json:"ruleId,omitempty"`
+	Level   string `json:"level,omitempty"` // error, warning or note
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// Label identifies the result in outputs as repo or repo/package
+func (r Result) Label() string {
+	if r.Package == "" {
+		return r.ProjectPath
+	}
+	return r.ProjectPath + "/" + r.Package
+}
+
+// OutputText joins the rows back into text, one line per row. Rows with more
+// than one cell are written as CSV records so a comma in a value can't be
+// mistaken for a separator, single cells such as lines of text are kept as is.
+func (r Result) OutputText() string {
+	lines := make([]string, len(r.Rows))
+	for i, row := range r.Rows {
+		cells := row.Cells()
+		if len(cells) == 1 {
+			lines[i] = cells[0]
+			continue
+		}
+		var sb strings.Builder
+		writer := csv.NewWriter(&sb)
+		writer.Write(cells)
+		writer.Flush()
+		lines[i] = strings.TrimSuffix(sb.String(), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
 // ScriptInfo represents information about a script
 type ScriptInfo struct {
-	Path    string   `json:"path"`
-	Version string   `json:"version"`
-	Output  string   `json:"output"`
-	Columns []string `json:"columns"`
+	Path     string   `json:"path"`
+	Version  string   `json:"version"`
+	Output   string   `json:"output"`
+	Columns  []string `json:"columns"`
+	Encoding string   `json:"encoding,omitempty"` // csv (default) or jsonl rows for csv output
+	Expect   []string `json:"expect,omitempty"`   // Expectations every project must meet, see run --expect
+	Template string   `json:"template,omitempty"` // text/template file, relative to the root directory, to render results with
 }
 
 func CleanPath(absPath string) string {
@@ -14,8 +14,9 @@ import (
 	lua "github.com/yuin/gopher-lua"
 )
 
-// RunFunc creates a Lua function that runs a script in a project
-func RunFunc(project *projects.Project) func(L *lua.LState) int {
+// RunFunc creates a Lua function that runs a script in a project. Scripts
+// excluded by the project's or its topics' run configuration raise an error.
+func RunFunc(pj *projects.ProjectsJSON, project *projects.Project) func(L *lua.LState) int {
 	return func(L *lua.LState) int {
 		script := L.CheckString(1)
 		arg := L.OptString(2, "")
@@ -24,16 +25,42 @@ func RunFunc(project *projects.Project) func(L *lua.LState) int {
 			L.RaiseError("failed to get script info: %v", err)
 			return 0
 		}
-		output, err := scripts.RunScriptForProject(nil, scriptInfo, project.Path, []string{arg}, false)
+		if pj.RunConfigFor(*project, scriptInfo.Path).Excluded {
+			L.RaiseError("%s is excluded for %s by its run configuration", scriptInfo.Path, project.Path)
+			return 0
+		}
+		if pj != nil {
+			// The runner resolves scripts from the root directory, like run
+			scriptInfo.Path = relativeToRoot(pj.RootDirectory, scriptInfo.Path)
+		}
+		output, err := scripts.RunScriptForProject(pj, scriptInfo, *project, []string{arg}, nil)
 		if err != nil {
 			L.RaiseError("failed to run script: %v", err)
 			return 0
 		}
-		L.Push(lua.LString(output.StdoutText))
+		L.Push(lua.LString(output.OutputText()))
 		return 1
 	}
 }
 
+// relativeToRoot makes a path from the working directory relative to the
+// root directory, returning it unchanged when that isn't possible.
+func relativeToRoot(rootDirectory string, path string) string {
+	root, err := filepath.Abs(rootDirectory)
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
 // ValueFunc creates a Lua function that reads values from files in a project
 func ValueFunc(project *projects.Project) func(L *lua.LState) int {
 	return func(L *lua.LState) int {
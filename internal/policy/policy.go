@@ -0,0 +1,166 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+// Operators in the order they are matched, two character operators first so
+// >= isn't read as >.
+var operators = []string{"==", "!=", ">=", "<=", "=~", "!~", ">", "<"}
+
+// Expectation is a single assertion about a script's output, such as
+// `output == true`, `version =~ ^18\.` or `count >= 5`.
+type Expectation struct {
+	Raw      string
+	Subject  string // output, rows, or a csv column / json field path
+	Operator string
+	Value    string
+
+	pattern *regexp.Regexp
+}
+
+// Parse reads an expectation written as `<subject> <operator> <value>`. The
+// subject is `output` for the whole output, `rows` for the number of rows, a
+// column name for csv scripts or a dot separated field for json scripts. An
+// expectation without an operator is shorthand for `output == <value>`.
+func Parse(raw string) (Expectation, error) {
+	e := Expectation{Raw: raw, Subject: "output", Operator: "==", Value: strings.TrimSpace(raw)}
+
+	index, operator := -1, ""
+	for _, op := range operators {
+		if i := strings.Index(raw, op); i > 0 && (index == -1 || i < index || (i == index && len(op) > len(operator))) {
+			index, operator = i, op
+		}
+	}
+	if index > 0 {
+		e.Subject = strings.TrimSpace(raw[:index])
+		e.Operator = operator
+		e.Value = strings.TrimSpace(raw[index+len(operator):])
+	}
+	e.Value = unquote(e.Value)
+	if e.Subject == "" {
+		return Expectation{}, fmt.Errorf("expectation %q has no subject", raw)
+	}
+
+	if e.Operator == "=~" || e.Operator == "!~" {
+		pattern, err := regexp.Compile(e.Value)
+		if err != nil {
+			return Expectation{}, fmt.Errorf("expectation %q: %w", raw, err)
+		}
+		e.pattern = pattern
+	}
+	if isOrdering(e.Operator) {
+		if _, err := strconv.ParseFloat(e.Value, 64); err != nil {
+			return Expectation{}, fmt.Errorf("expectation %q: %s needs a number", raw, e.Operator)
+		}
+	}
+	return e, nil
+}
+
+// ParseAll parses every expectation, stopping at the first invalid one.
+func ParseAll(raw []string) ([]Expectation, error) {
+	var expectations []Expectation
+	for _, r := range raw {
+		e, err := Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		expectations = append(expectations, e)
+	}
+	return expectations, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func isOrdering(operator string) bool {
+	return operator == ">" || operator == ">=" || operator == "<" || operator == "<="
+}
+
+func (e Expectation) String() string {
+	return fmt.Sprintf("%s %s %s", e.Subject, e.Operator, e.Value)
+}
+
+// Evaluate checks every expectation against a project's result and returns a
+// message for each violation. A script that didn't succeed is always a
+// violation.
+func Evaluate(info outputs.ScriptInfo, result outputs.Result, expectations []Expectation) []string {
+	var violations []string
+	if result.Status != "Success" {
+		violations = append(violations, result.Status)
+	}
+	for _, e := range expectations {
+		violations = append(violations, e.evaluate(info, result)...)
+	}
+	return violations
+}
+
+func (e Expectation) evaluate(info outputs.ScriptInfo, result outputs.Result) []string {
+	switch e.Subject {
+	case "output":
+		return e.check(strings.TrimSpace(result.OutputText()), "")
+	case "rows":
+		return e.check(strconv.Itoa(len(result.Rows)), "")
+	}
+
+	if len(result.Rows) == 0 {
+		return []string{fmt.Sprintf("%s: no rows", e)}
+	}
+	var violations []string
+	for i, row := range result.Rows {
+		value, ok := outputs.FieldValue(info, row, e.Subject)
+		if !ok {
+			violations = append(violations, fmt.Sprintf("%s: row %d has no %s", e, i+1, e.Subject))
+			continue
+		}
+		violations = append(violations, e.check(value, fmt.Sprintf("row %d ", i+1))...)
+	}
+	return violations
+}
+
+// check compares one value, returning a violation that names where the value
+// came from.
+func (e Expectation) check(actual string, where string) []string {
+	if e.matches(actual) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %sgot %q", e, where, actual)}
+}
+
+func (e Expectation) matches(actual string) bool {
+	switch e.Operator {
+	case "=~":
+		return e.pattern.MatchString(actual)
+	case "!~":
+		return !e.pattern.MatchString(actual)
+	}
+
+	actualNumber, actualErr := strconv.ParseFloat(actual, 64)
+	expectedNumber, expectedErr := strconv.ParseFloat(e.Value, 64)
+	numeric := actualErr == nil && expectedErr == nil
+
+	switch e.Operator {
+	case "==":
+		return actual == e.Value || (numeric && actualNumber == expectedNumber)
+	case "!=":
+		return actual != e.Value && !(numeric && actualNumber == expectedNumber)
+	case ">":
+		return numeric && actualNumber > expectedNumber
+	case ">=":
+		return numeric && actualNumber >= expectedNumber
+	case "<":
+		return numeric && actualNumber < expectedNumber
+	case "<=":
+		return numeric && actualNumber <= expectedNumber
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw      string
+		subject  string
+		operator string
+		value    string
+	}{
+		{"true", "output", "==", "true"},
+		{"output == 'yes'", "output", "==", "yes"},
+		{"count >= 5", "count", ">=", "5"},
+		{"version =~ ^18\\.", "version", "=~", "^18\\."},
+		{"engines.node != 16", "engines.node", "!=", "16"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.raw, err)
+		}
+		if e.Subject != tt.subject || e.Operator != tt.operator || e.Value != tt.value {
+			t.Errorf("Parse(%q) = %s, expected %s %s %s", tt.raw, e, tt.subject, tt.operator, tt.value)
+		}
+	}
+
+	for _, raw := range []string{"count > many", "name =~ ("} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", raw)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	csvInfo := outputs.ScriptInfo{Output: "csv", Columns: []string{"name", "count"}}
+	jsonInfo := outputs.ScriptInfo{Output: "json"}
+	textInfo := outputs.ScriptInfo{Output: "text"}
+
+	tests := []struct {
+		name       string
+		info       outputs.ScriptInfo
+		result     outputs.Result
+		expect     []string
+		violations int
+	}{
+		{"text equals", textInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{"true"}}}, []string{"true"}, 0},
+		{"text differs", textInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{"false"}}}, []string{"output == true"}, 1},
+		{"failed script", textInfo, outputs.Result{Status: "Failed (exit code 1)"}, nil, 1},
+		{"csv every row", csvInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{"a", "5"}, {"b", "2"}}}, []string{"count >= 5"}, 1},
+		{"csv no rows", csvInfo, outputs.Result{Status: "Success"}, []string{"count >= 5"}, 1},
+		{"row count", csvInfo, outputs.Result{Status: "Success"}, []string{"rows == 0"}, 0},
+		{"json field", jsonInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{map[string]any{"engines": map[string]any{"node": "18.2.0"}}}}}, []string{"engines.node =~ ^18\\."}, 0},
+		{"json string row", jsonInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{`{"x": 3}`}}}, []string{"x > 5"}, 1},
+		{"missing field", jsonInfo, outputs.Result{Status: "Success", Rows: []outputs.Row{{map[string]any{}}}}, []string{"x != 1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectations, err := ParseAll(tt.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			violations := Evaluate(tt.info, tt.result, expectations)
+			if len(violations) != tt.violations {
+				t.Errorf("Expected %d violations, got %d: %s", tt.violations, len(violations), strings.Join(violations, "; "))
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package projects
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ProjectFromURL derives a project name and path from a repo URL the same way
+// `add` does.
+func ProjectFromURL(repoURL string) Project {
+	projectName := strings.TrimSuffix(filepath.Base(strings.TrimSuffix(repoURL, "/")), ".git")
+	if projectName == "" || projectName == "." || projectName == "/" {
+		projectName = "unknown-project"
+	}
+	return Project{
+		Name:    projectName,
+		Path:    filepath.Join("projects", projectName),
+		RepoURL: repoURL,
+	}
+}
+
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// NormalizeRepoURL reduces a repo URL to host/owner/repo so the https, ssh and
+// scp-like forms of the same repo compare equal.
+func NormalizeRepoURL(repoURL string) string {
+	repoURL = strings.TrimSpace(repoURL)
+	var host, repoPath string
+	if u, err := url.Parse(repoURL); err == nil && u.Host != "" {
+		host, repoPath = u.Hostname(), u.Path
+	} else if m := scpLikeURL.FindStringSubmatch(repoURL); m != nil {
+		host, repoPath = m[1], m[2]
+	} else {
+		repoPath = repoURL
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	return strings.ToLower(strings.Trim(host+"/"+repoPath, "/"))
+}
+
+// MergeProjects appends the imported projects that aren't already present,
+// matching on repo URL, and returns the merged list and what was added. A repo
+// whose path is taken by another repo, such as org1/api and org2/api, gets
+// the owner as a prefix, projects/org2-api.
+func MergeProjects(existing []Project, imported []Project) ([]Project, []Project) {
+	seenURLs := make(map[string]bool)
+	seenPaths := make(map[string]bool)
+	for _, p := range existing {
+		seenURLs[NormalizeRepoURL(p.RepoURL)] = true
+		seenPaths[filepath.Clean(p.Path)] = true
+	}
+
+	merged := existing
+	var added []Project
+	for _, p := range imported {
+		normalized := NormalizeRepoURL(p.RepoURL)
+		if p.RepoURL == "" || seenURLs[normalized] {
+			continue
+		}
+		if seenPaths[filepath.Clean(p.Path)] {
+			p = withOwnerPrefix(p, normalized, seenPaths)
+		}
+		seenURLs[normalized] = true
+		seenPaths[filepath.Clean(p.Path)] = true
+		merged = append(merged, p)
+		added = append(added, p)
+	}
+	return merged, added
+}
+
+// withOwnerPrefix renames p to owner-name, adding a number if that path is
+// taken too.
+func withOwnerPrefix(p Project, normalizedURL string, seenPaths map[string]bool) Project {
+	parts := strings.Split(normalizedURL, "/")
+	name := p.Name
+	if len(parts) >= 2 {
+		name = parts[len(parts)-2] + "-" + p.Name
+	}
+	candidate := name
+	for i := 2; seenPaths[filepath.Clean(filepath.Join(filepath.Dir(p.Path), candidate))]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	p.Name = candidate
+	p.Path = filepath.Join(filepath.Dir(p.Path), candidate)
+	return p
+}
+
+// ParseURLList reads one repo URL per line. CSV files are supported by taking
+// the first field in each row that looks like a repo URL, so header rows and
+// extra columns are ignored. Blank lines and lines starting with # are skipped.
+func ParseURLList(data []byte) ([]Project, error) {
+	var out []Project
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("parse line %q: %w", line, err)
+		}
+		for _, field := range fields {
+			field = strings.TrimSpace(field)
+			if looksLikeRepoURL(field) {
+				out = append(out, ProjectFromURL(field))
+				break
+			}
+		}
+	}
+	return out, scanner.Err()
+}
+
+func looksLikeRepoURL(s string) bool {
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		return true
+	}
+	return strings.Contains(s, "@") && scpLikeURL.MatchString(s)
+}
+
+// ParseGitmodules reads the url of every submodule in a .gitmodules file.
+// Relative urls such as ../other.git are resolved against superprojectURL,
+// the url of the repo the file belongs to, the way git resolves them.
+func ParseGitmodules(data []byte, superprojectURL string) ([]Project, error) {
+	var out []Project
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		repoURL, err := ResolveRepoURL(superprojectURL, strings.TrimSpace(value), true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ProjectFromURL(repoURL))
+	}
+	return out, scanner.Err()
+}
+
+// repoManifest is the subset of a Google repo tool manifest used for imports.
+type repoManifest struct {
+	Remotes []struct {
+		Name  string `xml:"name,attr"`
+		Fetch string `xml:"fetch,attr"`
+	} `xml:"remote"`
+	Default struct {
+		Remote string `xml:"remote,attr"`
+	} `xml:"default"`
+	Projects []struct {
+		Name   string `xml:"name,attr"`
+		Path   string `xml:"path,attr"`
+		Remote string `xml:"remote,attr"`
+	} `xml:"project"`
+}
+
+// ParseRepoManifest reads the projects of a Google repo style XML manifest,
+// joining each project name onto the fetch URL of its remote. Relative fetch
+// URLs such as .. are resolved against manifestURL, the url of the repo the
+// manifest belongs to, the way repo resolves them.
+func ParseRepoManifest(data []byte, manifestURL string) ([]Project, error) {
+	var manifest repoManifest
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	fetchURLs := make(map[string]string)
+	for _, r := range manifest.Remotes {
+		fetch, err := ResolveRepoURL(manifestURL, r.Fetch, false)
+		if err != nil {
+			return nil, fmt.Errorf("remote %s: %w", r.Name, err)
+		}
+		fetchURLs[r.Name] = strings.TrimSuffix(fetch, "/")
+	}
+
+	var out []Project
+	for _, mp := range manifest.Projects {
+		remote := mp.Remote
+		if remote == "" {
+			remote = manifest.Default.Remote
+		}
+		fetch, ok := fetchURLs[remote]
+		if !ok {
+			return nil, fmt.Errorf("project %s uses unknown remote %q", mp.Name, remote)
+		}
+		project := ProjectFromURL(fetch + "/" + mp.Name)
+		if mp.Path != "" {
+			project.Name = filepath.Base(mp.Path)
+			project.Path = filepath.Join("projects", mp.Path)
+		}
+		out = append(out, project)
+	}
+	return out, nil
+}
+
+// ResolveRepoURL resolves a relative repo URL such as ../other.git against
+// base, the url of the repo it was read from. With asDir base is treated as a
+// directory like git does for submodules, so ../other.git next to
+// https://host/org/super.git is https://host/org/other.git. Other URLs are
+// returned unchanged.
+func ResolveRepoURL(base string, ref string, asDir bool) (string, error) {
+	if ref != "." && ref != ".." && !strings.HasPrefix(ref, "./") && !strings.HasPrefix(ref, "../") {
+		return ref, nil
+	}
+	if base == "" {
+		return "", fmt.Errorf("relative url %q needs the url of the repo it was read from, pass --base-url", ref)
+	}
+	if !strings.Contains(base, "://") && scpLikeURL.MatchString(base) && !filepath.IsAbs(base) {
+		// git@host:org/repo.git as ssh://git@host/org/repo.git
+		host, repoPath, _ := strings.Cut(base, ":")
+		base = "ssh://" + host + "/" + strings.TrimPrefix(repoPath, "/")
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil {
+		return "", fmt.Errorf("parse base url %q: %w", base, err)
+	}
+	if asDir {
+		baseURL.Path += "/"
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("parse url %q: %w", ref, err)
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// OriginURL returns the origin remote of the git repo containing dir, without
+// credentials added by `pull --githubUpdateToken`.
+func OriginURL(dir string) (string, error) {
+	origin, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", err
+	}
+	return stripCredentials(strings.TrimSpace(string(origin))), nil
+}
+
+// ParseGitHubRepoList reads an exported list of GitHub repositories. Both the
+// REST API format and `gh repo list --json name,url,repositoryTopics` are
+// supported, and topics are carried over when present.
+func ParseGitHubRepoList(data []byte) ([]Project, error) {
+	var repos []struct {
+		Name             string   `json:"name"`
+		CloneURL         string   `json:"clone_url"`
+		HTMLURL          string   `json:"html_url"`
+		URL              string   `json:"url"`
+		Topics           []string `json:"topics"`
+		Archived         bool     `json:"archived"`
+		IsArchived       bool     `json:"isArchived"`
+		RepositoryTopics []struct {
+			Name string `json:"name"`
+		} `json:"repositoryTopics"`
+	}
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("parse GitHub repo list: %w", err)
+	}
+
+	var out []Project
+	for _, r := range repos {
+		repoURL := r.CloneURL
+		if repoURL == "" {
+			repoURL = r.HTMLURL
+		}
+		if repoURL == "" || strings.HasPrefix(repoURL, "https://api.github.com/") {
+			repoURL = r.URL
+		}
+		if repoURL == "" {
+			continue
+		}
+		if !strings.HasSuffix(repoURL, ".git") {
+			repoURL += ".git"
+		}
+		project := ProjectFromURL(repoURL)
+		project.Topics = r.Topics
+		for _, topic := range r.RepositoryTopics {
+			project.Topics = append(project.Topics, topic.Name)
+		}
+		project.Skip = r.Archived || r.IsArchived
+		out = append(out, project)
+	}
+	return out, nil
+}
+
+// ScanDirectory finds every git clone below dir and reads its origin remote.
+// Paths are made relative to rootDirectory so they match projects.json.
+func ScanDirectory(dir string, rootDirectory string) ([]Project, error) {
+	var out []Project
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == dir {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			return nil
+		}
+
+		origin, err := OriginURL(path)
+		if err != nil {
+			fmt.Printf("%s Skipping clone without an origin remote\n", ProjectPathFmt(path))
+			return filepath.SkipDir
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(rootDirectory, absPath)
+		if err != nil {
+			return err
+		}
+
+		out = append(out, Project{
+			Name:    filepath.Base(path),
+			Path:    relPath,
+			RepoURL: origin,
+		})
+		return filepath.SkipDir
+	})
+	return out, err
+}
+
+// stripCredentials removes any token embedded by `pull --githubUpdateToken`.
+func stripCredentials(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User == nil {
+		return repoURL
+	}
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,140 @@
+package projects
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const LockFile = "projects.lock"
+
+// LockedProject is the commit a project was resolved to by the last pull.
+type LockedProject struct {
+	RepoURL string `json:"repoUrl"`
+	Commit  string `json:"commit"`
+}
+
+// Lock pins every project to a commit so runs can be reproduced. Projects are
+// keyed by their path in projects.json.
+type Lock struct {
+	Projects map[string]LockedProject `json:"projects"`
+}
+
+// LoadLock reads projects.lock from the root directory. A missing lockfile is
+// returned as an empty lock.
+func LoadLock(rootDirectory string) (*Lock, error) {
+	data, err := os.ReadFile(filepath.Join(rootDirectory, LockFile))
+	if os.IsNotExist(err) {
+		return &Lock{Projects: map[string]LockedProject{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", LockFile, err)
+	}
+	if lock.Projects == nil {
+		lock.Projects = map[string]LockedProject{}
+	}
+	return &lock, nil
+}
+
+// SaveLock writes the lock to projects.lock in the root directory.
+func SaveLock(rootDirectory string, lock *Lock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(rootDirectory, LockFile), append(data, '\n'), 0644)
+}
+
+// Prune removes the projects that are no longer in projects and returns their
+// paths, sorted.
+func (l *Lock) Prune(projects []Project) []string {
+	current := map[string]bool{}
+	for _, p := range projects {
+		current[p.Path] = true
+	}
+	var removed []string
+	for path := range l.Projects {
+		if !current[path] {
+			delete(l.Projects, path)
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// LockHash returns the sha256 of projects.lock, or an empty string when the
+// root directory has no lockfile.
+func LockHash(rootDirectory string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(rootDirectory, LockFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ResolveCommit returns the commit currently checked out in projectPath.
+func ResolveCommit(projectPath string) (string, error) {
+	out, err := exec.Command("git", "-C", projectPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("rev-parse HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CheckoutCommit detaches projectPath at commit, fetching from origin first
+// when the commit isn't available locally.
+func CheckoutCommit(projectPath string, commit string) error {
+	if err := exec.Command("git", "-C", projectPath, "cat-file", "-e", commit+"^{commit}").Run(); err != nil {
+		fmt.Printf("%s Fetching to find commit %s\n", ProjectPathFmt(projectPath), commit)
+		if out, err := exec.Command("git", "-C", projectPath, "fetch", "origin").CombinedOutput(); err != nil {
+			return fmt.Errorf("error fetching repository: %s\n%s", err, string(out))
+		}
+	}
+
+	fmt.Printf("%s Checking out locked commit %s\n", ProjectPathFmt(projectPath), commit)
+	out, err := exec.Command("git", "-C", projectPath, "checkout", "--detach", commit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error checking out %s: %s\n%s", commit, err, string(out))
+	}
+	return nil
+}
+
+// ReattachBranch checks out the default branch when projectPath is on a
+// detached HEAD, as left by pull --locked, so it can be pulled again.
+func ReattachBranch(projectPath string) error {
+	if exec.Command("git", "-C", projectPath, "symbolic-ref", "--quiet", "HEAD").Run() == nil {
+		return nil
+	}
+	candidates := []string{"main", "master"}
+	if branch := DefaultBranch(projectPath); branch != "HEAD" {
+		candidates = append([]string{strings.TrimPrefix(branch, "origin/")}, candidates...)
+	}
+	for _, branch := range candidates {
+		_, local := revParse(projectPath, branch)
+		_, remote := revParse(projectPath, "origin/"+branch)
+		if !local && !remote {
+			continue
+		}
+		fmt.Printf("%s Checking out %s after locked commit\n", ProjectPathFmt(projectPath), branch)
+		out, err := exec.Command("git", "-C", projectPath, "checkout", "--quiet", branch).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("error checking out %s: %s\n%s", branch, err, string(out))
+		}
+		return nil
+	}
+	return fmt.Errorf("HEAD is detached and no default branch was found, check out a branch to pull")
+}
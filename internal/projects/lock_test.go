@@ -0,0 +1,95 @@
+package projects
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLock_Missing(t *testing.T) {
+	lock, err := LoadLock(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error loading a missing lock, got %v", err)
+	}
+	if len(lock.Projects) != 0 {
+		t.Errorf("Expected an empty lock, got %v", lock.Projects)
+	}
+}
+
+func TestSaveLock_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	lock := &Lock{Projects: map[string]LockedProject{
+		"./projects/b": {RepoURL: "https://github.com/test/b.git", Commit: "bbb"},
+		"./projects/a": {RepoURL: "https://github.com/test/a.git", Commit: "aaa"},
+	}}
+	if err := SaveLock(dir, lock); err != nil {
+		t.Fatalf("Failed to save lock: %v", err)
+	}
+
+	loaded, err := LoadLock(dir)
+	if err != nil {
+		t.Fatalf("Failed to load lock: %v", err)
+	}
+	if loaded.Projects["./projects/a"].Commit != "aaa" || loaded.Projects["./projects/b"].Commit != "bbb" {
+		t.Errorf("Expected commits to round trip, got %v", loaded.Projects)
+	}
+
+	hash, err := LockHash(dir)
+	if err != nil {
+		t.Fatalf("Failed to hash lock: %v", err)
+	}
+	if err := SaveLock(dir, loaded); err != nil {
+		t.Fatalf("Failed to save lock: %v", err)
+	}
+	again, _ := LockHash(dir)
+	if hash == "" || hash != again {
+		t.Errorf("Expected a stable lock hash, got %q and %q", hash, again)
+	}
+}
+
+func TestLockHash_Missing(t *testing.T) {
+	hash, err := LockHash(t.TempDir())
+	if err != nil || hash != "" {
+		t.Errorf("Expected empty hash without a lockfile, got %q, %v", hash, err)
+	}
+}
+
+func TestReattachBranch(t *testing.T) {
+	repo := gitRepo(t)
+	commit, err := ResolveCommit(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckoutCommit(repo, commit); err != nil {
+		t.Fatalf("CheckoutCommit failed: %v", err)
+	}
+
+	if err := ReattachBranch(repo); err != nil {
+		t.Fatalf("ReattachBranch failed: %v", err)
+	}
+	out, err := exec.Command("git", "-C", repo, "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "main" {
+		t.Errorf("Expected main to be checked out, got %q (%v)", out, err)
+	}
+
+	// Already on a branch
+	if err := ReattachBranch(repo); err != nil {
+		t.Errorf("Expected no error on a branch, got %v", err)
+	}
+}
+
+func TestLock_Prune(t *testing.T) {
+	lock := &Lock{Projects: map[string]LockedProject{
+		"./projects/a":   {Commit: "aaa"},
+		"./projects/old": {Commit: "bbb"},
+		"./projects/b":   {Commit: "ccc"},
+	}}
+	removed := lock.Prune([]Project{{Path: "./projects/a"}, {Path: "./projects/b"}, {Path: "./projects/c"}})
+	if !reflect.DeepEqual(removed, []string{"./projects/old"}) {
+		t.Errorf("Expected ./projects/old to be removed, got %v", removed)
+	}
+	if len(lock.Projects) != 2 {
+		t.Errorf("Expected two locked projects, got %v", lock.Projects)
+	}
+}
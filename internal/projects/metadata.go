@@ -0,0 +1,55 @@
+package projects
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetadataValue looks up a dot separated field such as "language" or
+// "owner.login" in the project's synced metadata. Missing fields are empty.
+func (p Project) MetadataValue(field string) string {
+	// Metadata is a map once loaded from projects.json but a struct right after sync
+	var current any = p.Metadata
+	if _, isMap := current.(map[string]any); !isMap && current != nil {
+		data, err := json.Marshal(current)
+		if err != nil {
+			return ""
+		}
+		current = nil
+		json.Unmarshal(data, &current)
+	}
+
+	for _, key := range strings.Split(field, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return ""
+		}
+		current = m[key]
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// FindProject returns the project at path, or nil if there isn't one.
+func FindProject(projectsList []Project, path string) *Project {
+	for i := range projectsList {
+		if projectsList[i].Path == path {
+			return &projectsList[i]
+		}
+	}
+	return nil
+}
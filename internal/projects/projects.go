@@ -30,6 +30,12 @@ type Project struct {
 	Skip     bool              `json:"skip,omitempty"`
 	Metadata interface{}       `json:"metadata,omitempty"`
 	Git      map[string]string `json:"git,omitempty"`
+	Run      *RunConfig        `json:"run,omitempty"`
+
+	// Workspace is set on the virtual projects created by ExpandWorkspaces
+	Workspace *WorkspacePackage `json:"-"`
+	// Worktree is set when the project is checked out at another ref for a run
+	Worktree *Worktree `json:"-"`
 }
 
 // This is the defineition
@@ -83,8 +89,9 @@ func contains(slice []string, item string) bool {
 }
 
 type ProjectsJSON struct {
-	RootDirectory string    `json:"-"`
-	Projects      []Project `json:"projects"`
+	RootDirectory string                 `json:"-"`
+	Projects      []Project              `json:"projects"`
+	Topics        map[string]TopicConfig `json:"topics,omitempty"`
 }
 
 func findFileInParents(startDir, fileName string) (string, error) {
@@ -288,6 +295,10 @@ func CloneRepository(repoURL string, projectPath string, githubToken string, git
 					updateRemoteToken(repoURL, projectPath, githubToken, githubUser)
 				}
 			}
+			// pull --locked leaves HEAD detached, which git pull refuses
+			if err := ReattachBranch(projectPath); err != nil {
+				return err
+			}
 			// Git repo exists, do `git pull`
 			fmt.Printf("%s Repo cloned. Pulling latest changes from %s\n", ProjectPathFmt(projectPath), repoURL)
 			args := append([]string{"-C", projectPath, "pull"}, flagArgs...)
@@ -343,6 +354,21 @@ func ExtractTypeScriptCode(response string) string {
 	return ""
 }
 
+// ExtractSQLCode returns the first code block of a response, or the response
+// itself when it is a bare statement.
+func ExtractSQLCode(response string) string {
+	codeBlockRegex := regexp.MustCompile("(?s)```(?:sql|sqlite)?\\s*\\n(.+?)\\s*```")
+	if matches := codeBlockRegex.FindStringSubmatch(response); len(matches) >= 2 {
+		return strings.TrimSpace(matches[1])
+	}
+	trimmed := strings.TrimSpace(response)
+	upper := strings.ToUpper(trimmed)
+	if strings.HasPrefix(upper, "SELECT") || strings.HasPrefix(upper, "WITH") {
+		return trimmed
+	}
+	return ""
+}
+
 func ProjectPathFmt(projectPath string) string {
 	return fmt.Sprintf("\033[33m%s\033[0m", projectPath)
 }
@@ -48,3 +48,20 @@ func TestExtractTypeScriptCode_EmptyTypeScriptBlock(t *testing.T) {
 		t.Errorf("Expected '%s', but got '%s'", expected, result)
 	}
 }
+
+func TestExtractSQLCode(t *testing.T) {
+	tests := []struct {
+		response string
+		expected string
+	}{
+		{"Here you go:\n```sql\nSELECT name FROM deps;\n```\nThis counts...", "SELECT name FROM deps;"},
+		{"```\nWITH x AS (SELECT 1) SELECT * FROM x\n```", "WITH x AS (SELECT 1) SELECT * FROM x"},
+		{"  select count(*) from deps  ", "select count(*) from deps"},
+		{"I can't answer that from this schema.", ""},
+	}
+	for _, tt := range tests {
+		if result := ExtractSQLCode(tt.response); result != tt.expected {
+			t.Errorf("Expected '%s', but got '%s'", tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package projects
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ScriptRunConfig holds the overrides for a single script.
+type ScriptRunConfig struct {
+	Args []string          `json:"args,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+}
+
+// RunConfig customises how scripts run for a project or every project with a
+// topic. Scripts are keyed by path (scripts/foo.ts), file name (foo.ts) or
+// name without extension (foo).
+type RunConfig struct {
+	Dir     string                     `json:"dir,omitempty"`
+	Args    []string                   `json:"args,omitempty"`
+	Env     map[string]string          `json:"env,omitempty"`
+	Scripts map[string]ScriptRunConfig `json:"scripts,omitempty"`
+	Exclude []string                   `json:"exclude,omitempty"`
+}
+
+// TopicConfig is configuration shared by every project with a topic.
+type TopicConfig struct {
+	Run *RunConfig `json:"run,omitempty"`
+}
+
+// ResolvedRunConfig is the run configuration for one script in one project
+// after topic and project overrides are merged.
+type ResolvedRunConfig struct {
+	Dir      string
+	Args     []string
+	Env      map[string]string
+	Excluded bool
+}
+
+func scriptMatches(key string, scriptPath string) bool {
+	base := filepath.Base(scriptPath)
+	return key == scriptPath || key == filepath.Clean(scriptPath) || key == base || key == strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// apply layers rc on top of the resolved configuration.
+func (resolved *ResolvedRunConfig) apply(rc *RunConfig, scriptPath string) {
+	if rc == nil {
+		return
+	}
+	if rc.Dir != "" {
+		resolved.Dir = rc.Dir
+	}
+	resolved.Args = append(resolved.Args, rc.Args...)
+	maps.Copy(resolved.Env, rc.Env)
+
+	// Sort the keys so a script matched by several keys merges the same way every run
+	keys := slices.Collect(maps.Keys(rc.Scripts))
+	sort.Strings(keys)
+	for _, key := range keys {
+		if scriptMatches(key, scriptPath) {
+			resolved.Args = append(resolved.Args, rc.Scripts[key].Args...)
+			maps.Copy(resolved.Env, rc.Scripts[key].Env)
+		}
+	}
+	for _, key := range rc.Exclude {
+		if scriptMatches(key, scriptPath) {
+			resolved.Excluded = true
+		}
+	}
+}
+
+// RunConfigFor merges the run configuration of each of the project's topics, in
+// alphabetical order, and then the project's own configuration. A nil pj only
+// applies the project's configuration.
+func (pj *ProjectsJSON) RunConfigFor(project Project, scriptPath string) ResolvedRunConfig {
+	resolved := ResolvedRunConfig{Env: map[string]string{}}
+
+	if pj != nil {
+		topics := slices.Clone(project.Topics)
+		sort.Strings(topics)
+		for _, topic := range topics {
+			if tc, ok := pj.Topics[topic]; ok {
+				resolved.apply(tc.Run, scriptPath)
+			}
+		}
+	}
+	resolved.apply(project.Run, scriptPath)
+	return resolved
+}
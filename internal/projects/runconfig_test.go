@@ -0,0 +1,51 @@
+package projects
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunConfigFor(t *testing.T) {
+	pj := &ProjectsJSON{
+		Topics: map[string]TopicConfig{
+			"monorepo": {Run: &RunConfig{
+				Dir:  "packages/app",
+				Args: []string{"--workspace"},
+				Env:  map[string]string{"MODE": "monorepo"},
+			}},
+			"legacy": {Run: &RunConfig{Exclude: []string{"which-test-framework-is-being-used"}}},
+		},
+	}
+	project := Project{
+		Topics: []string{"monorepo", "legacy"},
+		Run: &RunConfig{
+			Dir: "web",
+			Env: map[string]string{"MODE": "project"},
+			Scripts: map[string]ScriptRunConfig{
+				"check-deps.ts": {Args: []string{"typescript"}, Env: map[string]string{"DEPTH": "1"}},
+			},
+		},
+	}
+
+	resolved := pj.RunConfigFor(project, "scripts/check-deps.ts")
+	expected := ResolvedRunConfig{
+		Dir:  "web",
+		Args: []string{"--workspace", "typescript"},
+		Env:  map[string]string{"MODE": "project", "DEPTH": "1"},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, resolved)
+	}
+
+	if !pj.RunConfigFor(project, "scripts/which-test-framework-is-being-used.ts").Excluded {
+		t.Errorf("Expected script excluded by topic to be excluded")
+	}
+}
+
+func TestRunConfigFor_NilProjectsJSON(t *testing.T) {
+	var pj *ProjectsJSON
+	resolved := pj.RunConfigFor(Project{Run: &RunConfig{Dir: "sub"}}, "scripts/a.ts")
+	if resolved.Dir != "sub" {
+		t.Errorf("Expected project run config to apply without projects.json, got %+v", resolved)
+	}
+}
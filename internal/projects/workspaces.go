@@ -0,0 +1,243 @@
+package projects
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspacePackage is a member of an npm, pnpm, Go or Cargo workspace. Dir is
+// relative to the project.
+type WorkspacePackage struct {
+	Name string
+	Dir  string
+}
+
+// ExpandWorkspaces replaces every project that is a workspace with one virtual
+// project per member package. Projects without workspaces are kept as is.
+// Projects whose workspaces can't be read are logged to w.
+func ExpandWorkspaces(w io.Writer, rootDirectory string, projectsList []Project) []Project {
+	var out []Project
+	for _, p := range projectsList {
+		packages, err := DetectWorkspacePackages(ProjectDir(rootDirectory, p))
+		if err != nil {
+			fmt.Fprintf(w, "%s Unable to detect workspaces: %v\n", ProjectPathFmt(p.Path), err)
+		}
+		if len(packages) == 0 {
+			out = append(out, p)
+			continue
+		}
+		for _, pkg := range packages {
+			virtual := p
+			virtual.Name = p.Name + "/" + pkg.Name
+			virtual.Workspace = &pkg
+			out = append(out, virtual)
+		}
+	}
+	return out
+}
+
+// DetectWorkspacePackages lists the members of the first workspace found in
+// projectDir: package.json workspaces, pnpm-workspace.yaml, go.work or a Cargo
+// workspace.
+func DetectWorkspacePackages(projectDir string) ([]WorkspacePackage, error) {
+	detectors := []struct {
+		file     string
+		patterns func([]byte) ([]string, error)
+		manifest string
+		name     func([]byte) string
+	}{
+		{"package.json", npmWorkspacePatterns, "package.json", npmPackageName},
+		{"pnpm-workspace.yaml", pnpmWorkspacePatterns, "package.json", npmPackageName},
+		{"go.work", goWorkUses, "go.mod", goModuleName},
+		{"Cargo.toml", cargoWorkspaceMembers, "Cargo.toml", cargoPackageName},
+	}
+
+	for _, d := range detectors {
+		data, err := os.ReadFile(filepath.Join(projectDir, d.file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		patterns, err := d.patterns(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", d.file, err)
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		return expandWorkspacePatterns(projectDir, patterns, d.manifest, d.name)
+	}
+	return nil, nil
+}
+
+// expandWorkspacePatterns globs the member patterns, dropping "!" negations and
+// directories without a manifest. ** is treated as a single directory level.
+func expandWorkspacePatterns(projectDir string, patterns []string, manifest string, name func([]byte) string) ([]WorkspacePackage, error) {
+	var dirs, excluded []string
+	for _, raw := range patterns {
+		negated := strings.HasPrefix(raw, "!")
+		pattern := strings.ReplaceAll(filepath.Clean(strings.TrimPrefix(raw, "!")), "**", "*")
+		matches, err := filepath.Glob(filepath.Join(projectDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			rel, _ := filepath.Rel(projectDir, m)
+			if negated {
+				excluded = append(excluded, rel)
+			} else {
+				dirs = append(dirs, rel)
+			}
+		}
+	}
+
+	var packages []WorkspacePackage
+	for _, dir := range dirs {
+		if slices.Contains(excluded, dir) || slices.ContainsFunc(packages, func(p WorkspacePackage) bool { return p.Dir == dir }) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(projectDir, dir, manifest))
+		if err != nil {
+			continue
+		}
+		pkgName := name(data)
+		if pkgName == "" {
+			pkgName = filepath.ToSlash(dir)
+		}
+		packages = append(packages, WorkspacePackage{Name: pkgName, Dir: dir})
+	}
+	return packages, nil
+}
+
+func npmWorkspacePatterns(data []byte) ([]string, error) {
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil || len(pkg.Workspaces) == 0 {
+		return nil, err
+	}
+	var patterns []string
+	if err := json.Unmarshal(pkg.Workspaces, &patterns); err == nil {
+		return patterns, nil
+	}
+	// Yarn also allows { "packages": [...], "nohoist": [...] }
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(pkg.Workspaces, &object); err != nil {
+		return nil, err
+	}
+	return object.Packages, nil
+}
+
+func npmPackageName(data []byte) string {
+	var pkg struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(data, &pkg)
+	return pkg.Name
+}
+
+func pnpmWorkspacePatterns(data []byte) ([]string, error) {
+	var workspace struct {
+		Packages []string `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(data, &workspace); err != nil {
+		return nil, err
+	}
+	return workspace.Packages, nil
+}
+
+// goWorkUses reads the use directives of a go.work file, in either the single
+// line or block form.
+func goWorkUses(data []byte) ([]string, error) {
+	var uses []string
+	inBlock := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.Split(scanner.Text(), "//")[0])
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock && line != "":
+			uses = append(uses, strings.Trim(line, `"`))
+		case line == "use (":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			uses = append(uses, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "use ")), `"`))
+		}
+	}
+	return uses, scanner.Err()
+}
+
+func goModuleName(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if module, found := strings.CutPrefix(strings.TrimSpace(line), "module "); found {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	return ""
+}
+
+var (
+	tomlSection = regexp.MustCompile(`(?m)^\s*\[([^\]]+)\]\s*$`)
+	tomlString  = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// tomlSectionBody returns the text of a [section] up to the next section.
+func tomlSectionBody(data []byte, section string) string {
+	text := string(data)
+	locs := tomlSection.FindAllStringSubmatchIndex(text, -1)
+	for i, loc := range locs {
+		if strings.TrimSpace(text[loc[2]:loc[3]]) != section {
+			continue
+		}
+		end := len(text)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		return text[loc[1]:end]
+	}
+	return ""
+}
+
+// tomlStringArray reads a key = ["a", "b"] array, which may span lines.
+func tomlStringArray(body string, key string) []string {
+	re := regexp.MustCompile(`(?ms)^\s*` + regexp.QuoteMeta(key) + `\s*=\s*\[(.*?)\]`)
+	m := re.FindStringSubmatch(body)
+	if m == nil {
+		return nil
+	}
+	var values []string
+	for _, s := range tomlString.FindAllStringSubmatch(m[1], -1) {
+		values = append(values, s[1])
+	}
+	return values
+}
+
+func cargoWorkspaceMembers(data []byte) ([]string, error) {
+	body := tomlSectionBody(data, "workspace")
+	members := tomlStringArray(body, "members")
+	for _, exclude := range tomlStringArray(body, "exclude") {
+		members = append(members, "!"+exclude)
+	}
+	return members, nil
+}
+
+func cargoPackageName(data []byte) string {
+	m := regexp.MustCompile(`(?m)^\s*name\s*=\s*"([^"]*)"`).FindStringSubmatch(tomlSectionBody(data, "package"))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
@@ -0,0 +1,118 @@
+package projects
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestDetectWorkspacePackages(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []WorkspacePackage
+	}{
+		{
+			name: "npm",
+			files: map[string]string{
+				"package.json":                   `{"workspaces": ["packages/*", "!packages/internal"]}`,
+				"packages/a/package.json":        `{"name": "@scope/a"}`,
+				"packages/b/package.json":        `{}`,
+				"packages/internal/package.json": `{"name": "internal"}`,
+				"packages/docs/README.md":        `# Not a package`,
+			},
+			expected: []WorkspacePackage{{Name: "@scope/a", Dir: "packages/a"}, {Name: "packages/b", Dir: "packages/b"}},
+		},
+		{
+			name: "yarn",
+			files: map[string]string{
+				"package.json":          `{"workspaces": {"packages": ["apps/*"]}}`,
+				"apps/web/package.json": `{"name": "web"}`,
+			},
+			expected: []WorkspacePackage{{Name: "web", Dir: "apps/web"}},
+		},
+		{
+			name: "pnpm",
+			files: map[string]string{
+				"package.json":           `{"name": "root"}`,
+				"pnpm-workspace.yaml":    "packages:\n  - 'libs/**'\n",
+				"libs/core/package.json": `{"name": "core"}`,
+			},
+			expected: []WorkspacePackage{{Name: "core", Dir: "libs/core"}},
+		},
+		{
+			name: "go",
+			files: map[string]string{
+				"go.work":    "go 1.24\n\nuse (\n\t./api\n\t./cli // tools\n)\n",
+				"api/go.mod": "module example.com/api\n",
+				"cli/go.mod": "module example.com/cli\n",
+			},
+			expected: []WorkspacePackage{{Name: "example.com/api", Dir: "api"}, {Name: "example.com/cli", Dir: "cli"}},
+		},
+		{
+			name: "cargo",
+			files: map[string]string{
+				"Cargo.toml":             "[workspace]\nmembers = [\n  \"crates/*\",\n]\nexclude = [\"crates/old\"]\n\n[workspace.dependencies]\nserde = \"1\"\n",
+				"crates/core/Cargo.toml": "[package]\nname = \"core\"\nversion = \"0.1.0\"\n",
+				"crates/old/Cargo.toml":  "[package]\nname = \"old\"\n",
+			},
+			expected: []WorkspacePackage{{Name: "core", Dir: "crates/core"}},
+		},
+		{
+			name:     "no workspace",
+			files:    map[string]string{"package.json": `{"name": "app"}`},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			got, err := DetectWorkspacePackages(dir)
+			if err != nil {
+				t.Fatalf("DetectWorkspacePackages failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExpandWorkspaces(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"projects/mono/package.json":            `{"workspaces": ["packages/*"]}`,
+		"projects/mono/packages/a/package.json": `{"name": "a"}`,
+		"projects/single/package.json":          `{"name": "single"}`,
+	})
+
+	expanded := ExpandWorkspaces(io.Discard, root, []Project{
+		{Name: "mono", Path: "projects/mono"},
+		{Name: "single", Path: "projects/single"},
+	})
+	if len(expanded) != 2 {
+		t.Fatalf("Expected 2 projects, got %v", expanded)
+	}
+	if expanded[0].Name != "mono/a" || expanded[0].Workspace == nil || expanded[0].Workspace.Dir != "packages/a" {
+		t.Errorf("Expected virtual project mono/a, got %+v", expanded[0])
+	}
+	if expanded[1].Name != "single" || expanded[1].Workspace != nil {
+		t.Errorf("Expected single to be unchanged, got %+v", expanded[1])
+	}
+}
@@ -0,0 +1,101 @@
+package projects
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a temporary checkout of a project at another ref, created with
+// `git worktree` so the main checkout is left untouched.
+type Worktree struct {
+	Dir      string
+	Ref      string // The ref that was checked out, after any fallback
+	Commit   string
+	Fallback bool // The requested ref wasn't found and Ref is the default branch
+
+	projectDir string
+}
+
+// ProjectDir returns the directory scripts should run in for p: its worktree
+// when one was created, otherwise its clone under the root directory.
+func ProjectDir(rootDirectory string, p Project) string {
+	if p.Worktree != nil {
+		return p.Worktree.Dir
+	}
+	return filepath.Join(rootDirectory, p.Path)
+}
+
+func revParse(projectDir string, rev string) (string, bool) {
+	out, err := exec.Command("git", "-C", projectDir, "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// DefaultBranch returns the remote default branch, e.g. origin/main, falling
+// back to HEAD when origin/HEAD isn't set.
+func DefaultBranch(projectDir string) string {
+	out, err := exec.Command("git", "-C", projectDir, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD").Output()
+	if err != nil {
+		return "HEAD"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ResolveRef finds the commit for a branch, tag or sha. Remote branches and refs
+// that have to be fetched, such as pull/123/head, are tried next. When the ref
+// doesn't exist the default branch is used and returned as the resolved ref.
+func ResolveRef(projectDir string, ref string) (string, string, error) {
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		if commit, ok := revParse(projectDir, candidate); ok {
+			return candidate, commit, nil
+		}
+	}
+	if err := exec.Command("git", "-C", projectDir, "fetch", "--quiet", "origin", ref).Run(); err == nil {
+		if commit, ok := revParse(projectDir, "FETCH_HEAD"); ok {
+			return ref, commit, nil
+		}
+	}
+
+	fallback := DefaultBranch(projectDir)
+	commit, ok := revParse(projectDir, fallback)
+	if !ok {
+		return "", "", fmt.Errorf("unable to resolve %s or the default branch %s", ref, fallback)
+	}
+	return fallback, commit, nil
+}
+
+// AddWorktree checks out ref into a temporary directory. Call Remove when done.
+func AddWorktree(projectDir string, ref string) (*Worktree, error) {
+	resolvedRef, commit, err := ResolveRef(projectDir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "query-projects-worktree-*")
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "-C", projectDir, "worktree", "add", "--detach", dir, commit).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("error adding worktree: %s\n%s", err, string(out))
+	}
+
+	fallback := resolvedRef != ref && resolvedRef != "origin/"+ref
+	return &Worktree{Dir: dir, Ref: resolvedRef, Commit: commit, Fallback: fallback, projectDir: projectDir}, nil
+}
+
+// Remove deletes the worktree and its temporary directory.
+func (w *Worktree) Remove() error {
+	out, err := exec.Command("git", "-C", w.projectDir, "worktree", "remove", "--force", w.Dir).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(w.Dir)
+		return fmt.Errorf("error removing worktree: %s\n%s", err, string(out))
+	}
+	return nil
+}
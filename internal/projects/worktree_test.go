@@ -0,0 +1,66 @@
+package projects
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func gitRepo(t *testing.T) string {
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "--quiet", "--initial-branch=main")
+	writeFiles(t, dir, map[string]string{"README.md": "main"})
+	run("add", ".")
+	run("commit", "--quiet", "-m", "main")
+	run("checkout", "--quiet", "-b", "develop")
+	writeFiles(t, dir, map[string]string{"README.md": "develop"})
+	run("commit", "--quiet", "-am", "develop")
+	run("checkout", "--quiet", "main")
+	return dir
+}
+
+func TestAddWorktree(t *testing.T) {
+	repo := gitRepo(t)
+
+	wt, err := AddWorktree(repo, "develop")
+	if err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(wt.Dir, "README.md"))
+	if wt.Ref != "develop" || string(content) != "develop" {
+		t.Errorf("Expected develop checked out, got ref %s with %q", wt.Ref, content)
+	}
+	main, _ := os.ReadFile(filepath.Join(repo, "README.md"))
+	if string(main) != "main" {
+		t.Errorf("Expected main checkout to be untouched, got %q", main)
+	}
+
+	if err := wt.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(wt.Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected worktree directory to be removed")
+	}
+}
+
+func TestAddWorktree_FallsBackToDefaultBranch(t *testing.T) {
+	repo := gitRepo(t)
+
+	wt, err := AddWorktree(repo, "release/missing")
+	if err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	defer wt.Remove()
+
+	content, _ := os.ReadFile(filepath.Join(wt.Dir, "README.md"))
+	if wt.Ref != "HEAD" || !wt.Fallback || string(content) != "main" {
+		t.Errorf("Expected fallback to HEAD, got ref %s with %q", wt.Ref, content)
+	}
+}
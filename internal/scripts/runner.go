@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/wcatron/query-projects/internal/outputs"
@@ -42,9 +43,28 @@ func prefixLines(s, prefix string) string {
 }
 
 // RunScriptForProject runs a TypeScript script (with Deno) in the specified project directory.
-func RunScriptForProject(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInfo, projectPath string, args []string, print bool) (outputs.Result, error) {
-	if print {
-		fmt.Printf("%s Running %s...\n", projects.ProjectPathFmt(projectPath), ScriptPathFmt(scriptInfo.Path))
+// The project's run configuration sets the working subdirectory and adds arguments and environment variables.
+// args are passed to the script joined as its first argument, followed by the configured arguments.
+func RunScriptForProject(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInfo, project projects.Project, args []string, log io.Writer) (outputs.Result, error) {
+	label := project.Path
+	runConfig := pj.RunConfigFor(project, scriptInfo.Path)
+	var packageName string
+	if project.Workspace != nil {
+		// Workspace packages run in their own directory instead of the configured one
+		packageName = project.Workspace.Name
+		runConfig.Dir = project.Workspace.Dir
+		label = project.Path + "/" + packageName
+	}
+	if runConfig.Excluded {
+		return outputs.Result{}, fmt.Errorf("%s is excluded for %s by its run configuration", scriptInfo.Path, project.Path)
+	}
+	var ref string
+	if project.Worktree != nil {
+		ref = project.Worktree.Ref
+		label = label + "@" + ref
+	}
+	if log != nil {
+		fmt.Fprintf(log, "%s Running %s...\n", projects.ProjectPathFmt(label), ScriptPathFmt(scriptInfo.Path))
 	}
 
 	var rootDirectory string
@@ -56,8 +76,20 @@ func RunScriptForProject(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInf
 
 	scriptPath := filepath.Join(rootDirectory, scriptInfo.Path)
 
-	cmd := exec.Command("deno", "run", "--allow-all", scriptPath, strings.Join(args, " "))
-	cmd.Dir = filepath.Join(rootDirectory, projectPath)
+	cmdArgs := append([]string{"run", "--allow-all", scriptPath, strings.Join(args, " ")}, runConfig.Args...)
+	cmd := exec.Command("deno", cmdArgs...)
+	cmd.Dir = filepath.Join(projects.ProjectDir(rootDirectory, project), runConfig.Dir)
+	cmd.Env = os.Environ()
+	for k, v := range runConfig.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	// Scripts can send typed rows, warnings and progress as NDJSON records
+	records, err := attachRecords(cmd)
+	if err != nil {
+		return outputs.Result{}, err
+	}
+	defer records.close()
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -71,22 +103,28 @@ func RunScriptForProject(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInf
 	if err := cmd.Start(); err != nil {
 		return outputs.Result{}, err
 	}
+	records.start(scriptInfo, func(message string) {
+		if log != nil {
+			fmt.Fprintf(log, "%s %s\n", projects.ProjectPathFmt(label), message)
+		}
+	})
 
 	stdoutBytes, _ := io.ReadAll(stdoutPipe)
 	stderrBytes, _ := io.ReadAll(stderrPipe)
 
 	// Wait for command completion
 	err = cmd.Wait()
+	received := records.wait()
 
 	StdoutText := string(stdoutBytes)
 	StderrText := string(stderrBytes)
 
-	if print {
+	if log != nil {
 		if len(StdoutText) > 0 {
-			fmt.Printf("%s\n", prefixLines(StdoutText, projects.ProjectPathFmt(projectPath)))
+			fmt.Fprintf(log, "%s\n", prefixLines(StdoutText, projects.ProjectPathFmt(label)))
 		}
 		if len(StderrText) > 0 {
-			fmt.Printf("%s\n", prefixLines(StderrText, projects.ProjectPathFmt(projectPath)))
+			fmt.Fprintf(log, "%s\n", prefixLines(StderrText, projects.ProjectPathFmt(label)))
 		}
 	}
 
@@ -97,24 +135,127 @@ func RunScriptForProject(pj *projects.ProjectsJSON, scriptInfo outputs.ScriptInf
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			// TODO: Determine if this status is ever reached
 			status = fmt.Sprintf("Failed (exit code %d)", exitErr.ExitCode())
-			if print {
-				fmt.Printf("%s Script %s failed %s\n", projects.ProjectPathFmt(projectPath), scriptInfo.Path, exitErr.Error())
+			if log != nil {
+				fmt.Fprintf(log, "%s Script %s failed %s\n", projects.ProjectPathFmt(label), scriptInfo.Path, exitErr.Error())
 			}
 		} else {
 			status = "Error"
-			if print {
-				fmt.Printf("%s Error running script %s error %v\n", projects.ProjectPathFmt(projectPath), scriptInfo.Path, err)
+			if log != nil {
+				fmt.Fprintf(log, "%s Error running script %s error %v\n", projects.ProjectPathFmt(label), scriptInfo.Path, err)
 			}
 		}
-		if print {
-			fmt.Printf("%s %s %s\n", projects.ProjectPathFmt(projectPath), status, ScriptPathFmt(scriptInfo.Path))
+		if log != nil {
+			fmt.Fprintf(log, "%s %s %s\n", projects.ProjectPathFmt(label), status, ScriptPathFmt(scriptInfo.Path))
+		}
+	}
+
+	rows, rowErrs := received.Rows, received.Errors
+	if !received.Received {
+		var parseErrs []error
+		rows, parseErrs = outputs.ParseRows(scriptInfo, strings.TrimSpace(StdoutText))
+		rowErrs = append(rowErrs, parseErrs...)
+	}
+	if log != nil {
+		for _, warning := range received.Warnings {
+			fmt.Fprintf(log, "%s Warning: %s\n", projects.ProjectPathFmt(label), warning)
+		}
+	}
+	if len(rowErrs) > 0 {
+		if status == "Success" {
+			status = fmt.Sprintf("Malformed (%d rows)", len(rowErrs))
+		}
+		if log != nil {
+			for _, rowErr := range rowErrs {
+				fmt.Fprintf(log, "%s Malformed row: %v\n", projects.ProjectPathFmt(label), rowErr)
+			}
 		}
 	}
 
 	return outputs.Result{
-		ProjectPath: projectPath,
+		ProjectPath: project.Path,
+		Package:     packageName,
+		Ref:         ref,
+		Dir:         filepath.Clean(runConfig.Dir),
 		Status:      status,
 		StdoutText:  strings.TrimSpace(StdoutText),
 		StderrText:  strings.TrimSpace(StderrText),
+		Rows:        rows,
+		RowErrors:   rowErrs,
+		Warnings:    received.Warnings,
+		Findings:    received.Findings,
 	}, nil
 }
+
+// recordsChannel carries the records a script sends. They arrive on fd 3 while
+// the script runs, except on Windows where a process can't be passed extra
+// files, so they are written to a temp file and read once the script exits.
+type recordsChannel struct {
+	reader *os.File // Read end of the fd 3 pipe
+	writer *os.File
+	path   string // Temp file on Windows
+	read   func(r io.Reader) outputs.Records
+	result chan outputs.Records
+}
+
+// attachRecords tells the script where to send records, adding fd 3 or the
+// temp file to cmd.
+func attachRecords(cmd *exec.Cmd) (*recordsChannel, error) {
+	if runtime.GOOS == "windows" {
+		f, err := os.CreateTemp("", "query-projects-records-*.ndjson")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		cmd.Env = append(cmd.Env, outputs.RecordsFileEnv+"="+f.Name())
+		return &recordsChannel{path: f.Name()}, nil
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.ExtraFiles = []*os.File{writer}
+	cmd.Env = append(cmd.Env, outputs.RecordsFDEnv+"=3")
+	return &recordsChannel{reader: reader, writer: writer}, nil
+}
+
+// start begins reading records once the script has started, calling progress
+// with each progress message.
+func (c *recordsChannel) start(scriptInfo outputs.ScriptInfo, progress func(message string)) {
+	c.read = func(r io.Reader) outputs.Records {
+		return outputs.ReadRecords(scriptInfo, r, progress)
+	}
+	if c.reader == nil {
+		return
+	}
+	// Close our copy so reading stops once the script exits
+	c.writer.Close()
+	c.writer = nil
+	c.result = make(chan outputs.Records, 1)
+	go func() { c.result <- c.read(c.reader) }()
+}
+
+// wait returns the records the script sent, once it has exited.
+func (c *recordsChannel) wait() outputs.Records {
+	if c.reader != nil {
+		return <-c.result
+	}
+	f, err := os.Open(c.path)
+	if err != nil {
+		return outputs.Records{}
+	}
+	defer f.Close()
+	return c.read(f)
+}
+
+func (c *recordsChannel) close() {
+	if c.writer != nil {
+		c.writer.Close()
+	}
+	if c.reader != nil {
+		c.reader.Close()
+	}
+	if c.path != "" {
+		os.Remove(c.path)
+	}
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// dashboard serves the web UI. The assets are embedded so the binary stays
+// self contained; they call the API with the token the user enters.
+func dashboard() http.Handler {
+	files, _ := fs.Sub(webFiles, "web")
+	return http.FileServerFS(files)
+}
@@ -0,0 +1,528 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+// RunRequest is the body of POST /api/runs. Script is a path from
+// /api/scripts and topics use the same filter syntax as run --topics.
+type RunRequest struct {
+	Script string   `json:"script"`
+	Topics []string `json:"topics,omitempty"`
+	Args   []string `json:"args,omitempty"`
+}
+
+// RunFunc runs a script for the projects matching a request. It calls progress
+// with the number of projects to run once they are known and again as each
+// project finishes, and log with messages about each project as it runs.
+type RunFunc func(req RunRequest, progress func(completed, total int), log func(project, message string)) (outputs.ScriptInfo, []outputs.Result, error)
+
+// Options configures the API. Projects, Scripts and Run are called for every
+// request so the API reflects the files on disk, and can be replaced in tests.
+type Options struct {
+	Token    string // Required as a bearer token on every request
+	DBPath   string // Results database for /api/query, runs are saved to it
+	Projects func() (*projects.ProjectsJSON, error)
+	Scripts  func() ([]outputs.ScriptInfo, error)
+	Run      RunFunc
+}
+
+// Run statuses
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// LogEntry is a message about one project in a run.
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Project string    `json:"project"`
+	Message string    `json:"message"`
+}
+
+// Change is how a project's result differs between two runs, one of changed,
+// added or removed.
+type Change struct {
+	Project string         `json:"project"`
+	Change  string         `json:"change"`
+	Before  map[string]any `json:"before,omitempty"`
+	After   map[string]any `json:"after,omitempty"`
+}
+
+// run is a run started through the API. Fields are guarded by the server's mutex.
+type run struct {
+	ID        int              `json:"id"`
+	Script    string           `json:"script"`
+	Topics    []string         `json:"topics,omitempty"`
+	Args      []string         `json:"args,omitempty"`
+	Status    string           `json:"status"`
+	Started   time.Time        `json:"started"`
+	Finished  *time.Time       `json:"finished,omitempty"`
+	Completed int              `json:"completed"`
+	Total     int              `json:"total"`
+	Error     string           `json:"error,omitempty"`
+	RunID     int64            `json:"runId,omitempty"` // Run the results were written under in the runs table
+	Results   []map[string]any `json:"results,omitempty"`
+	Log       []LogEntry       `json:"log,omitempty"`
+
+	// Closed and replaced whenever the run changes, to wake log streams
+	updated chan struct{}
+}
+
+// notify wakes the log streams waiting on the run. The server's mutex must be held.
+func (r *run) notify() {
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+type server struct {
+	opts Options
+	mu   sync.Mutex
+	runs []*run
+}
+
+// New returns the API handler:
+//
+//	GET  /api/projects?topics=a,%2Bb,-c  projects matching the topics
+//	GET  /api/scripts                   scripts with their info
+//	POST /api/runs                      start a run, returns its id
+//	GET  /api/runs                      runs without their results
+//	GET  /api/runs/{id}                 a run's progress, results and log
+//	GET  /api/runs/{id}/log             the run's log as server-sent events
+//	GET  /api/runs/{id}/diff?against=   changes since another run, by default
+//	                                    the previous run of the same script,
+//	                                    topics and args
+//	POST /api/query                     {"sql": "..."} run read-only SQL
+//
+// Every API request needs an Authorization: Bearer <token> header. Everything
+// else serves the dashboard, which asks for the token.
+//
+// Runs are saved to the results database as they start and finish, in the
+// serve_runs table, with their results written to the runs and
+// <script>_results tables like run --output sqlite. New loads the runs saved
+// by earlier servers so the history and diffs survive a restart.
+func New(opts Options) (http.Handler, error) {
+	s := &server{opts: opts}
+	if err := s.loadRuns(); err != nil {
+		return nil, err
+	}
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/projects", s.listProjects)
+	api.HandleFunc("GET /api/scripts", s.listScripts)
+	api.HandleFunc("POST /api/runs", s.startRun)
+	api.HandleFunc("GET /api/runs", s.listRuns)
+	api.HandleFunc("GET /api/runs/{id}", s.getRun)
+	api.HandleFunc("GET /api/runs/{id}/log", s.streamLog)
+	api.HandleFunc("GET /api/runs/{id}/diff", s.diffRun)
+	api.HandleFunc("POST /api/query", s.query)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", s.authorize(api))
+	mux.Handle("/", dashboard())
+	return mux, nil
+}
+
+// loadRuns reads the runs saved by earlier servers. Runs that were still
+// running when their server stopped are marked failed.
+func (s *server) loadRuns() error {
+	if _, err := os.Stat(s.opts.DBPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	db, err := store.OpenReadOnly(s.opts.DBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	saved, err := store.ServeRuns(db)
+	if err != nil {
+		return fmt.Errorf("load runs: %w", err)
+	}
+	for _, data := range saved {
+		current := &run{updated: make(chan struct{})}
+		if err := json.Unmarshal(data, current); err != nil {
+			return fmt.Errorf("load runs: %w", err)
+		}
+		if current.Status == StatusRunning {
+			current.Status, current.Error = StatusFailed, "interrupted, the server stopped before the run finished"
+		}
+		s.runs = append(s.runs, current)
+	}
+	return nil
+}
+
+// openDB opens the results database for writing, creating its folder.
+func (s *server) openDB() (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.opts.DBPath), 0o755); err != nil {
+		return nil, fmt.Errorf("create results folder: %w", err)
+	}
+	return store.Open(s.opts.DBPath)
+}
+
+// saveRun writes the run to the results database. The server's mutex must be
+// held, which also keeps runs from writing to the database at the same time.
+func (s *server) saveRun(current *run) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	db, err := s.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return store.SaveServeRun(db, current.ID, current.RunID, data)
+}
+
+// writeResults writes a finished run's results like run --output sqlite and
+// returns the id of the run in the runs table. The server's mutex must be held.
+func (s *server) writeResults(current *run, info outputs.ScriptInfo, results []outputs.Result) (int64, error) {
+	meta := outputs.RunMetadata{
+		Script:   info.Path,
+		Args:     current.Args,
+		Started:  current.Started,
+		Duration: current.Finished.Sub(current.Started).String(),
+	}
+	if pj, err := s.opts.Projects(); err == nil {
+		meta.LockHash, _ = projects.LockHash(pj.RootDirectory)
+	}
+	db, err := s.openDB()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return store.WriteResults(db, info, results, meta, store.ModeAppend)
+}
+
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.opts.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) listProjects(w http.ResponseWriter, r *http.Request) {
+	pj, err := s.opts.Projects()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var topics []string
+	if raw := r.URL.Query().Get("topics"); raw != "" {
+		for _, topic := range strings.Split(raw, ",") {
+			trimmed := strings.TrimSpace(topic)
+			// An unescaped + in a query string decodes to a space, keep it as +
+			if strings.HasPrefix(topic, " ") && !strings.HasPrefix(trimmed, "+") && !strings.HasPrefix(trimmed, "-") {
+				trimmed = "+" + trimmed
+			}
+			if trimmed != "" {
+				topics = append(topics, trimmed)
+			}
+		}
+	}
+	matched := projects.FilterProjectsByTopics(pj.Projects, topics)
+	if matched == nil {
+		matched = []projects.Project{}
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
+
+func (s *server) listScripts(w http.ResponseWriter, r *http.Request) {
+	scriptInfos, err := s.opts.Scripts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, scriptInfos)
+}
+
+func (s *server) startRun(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid run request: %w", err))
+		return
+	}
+	if req.Script == "" {
+		writeError(w, http.StatusBadRequest, errors.New("script is required"))
+		return
+	}
+	// Only scripts in the scripts folder can be run
+	scriptInfos, err := s.opts.Scripts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !slices.ContainsFunc(scriptInfos, func(info outputs.ScriptInfo) bool { return info.Path == req.Script }) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no script %s, expected a path from /api/scripts", req.Script))
+		return
+	}
+
+	s.mu.Lock()
+	current := &run{ID: len(s.runs) + 1, Script: req.Script, Topics: req.Topics, Args: req.Args,
+		Status: StatusRunning, Started: time.Now(), updated: make(chan struct{})}
+	if err := s.saveRun(current); err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("save run: %w", err))
+		return
+	}
+	s.runs = append(s.runs, current)
+	s.mu.Unlock()
+
+	// The run outlives the request that started it
+	go func() {
+		info, results, err := s.opts.Run(req, func(completed, total int) {
+			s.mu.Lock()
+			current.Completed, current.Total = completed, total
+			current.notify()
+			s.mu.Unlock()
+		}, func(project, message string) {
+			s.mu.Lock()
+			current.Log = append(current.Log, LogEntry{Time: time.Now(), Project: project, Message: message})
+			current.notify()
+			s.mu.Unlock()
+		})
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		defer current.notify()
+		finished := time.Now()
+		current.Finished = &finished
+		if err != nil {
+			current.Status, current.Error = StatusFailed, err.Error()
+		} else {
+			current.Status = StatusDone
+			current.Results = make([]map[string]any, len(results))
+			for i, result := range results {
+				current.Results[i] = outputs.JSONEntry(info, result)
+			}
+			if current.RunID, err = s.writeResults(current, info, results); err != nil {
+				current.Log = append(current.Log, LogEntry{Time: finished, Message: fmt.Sprintf("Error writing results: %v", err)})
+			}
+		}
+		if err := s.saveRun(current); err != nil {
+			current.Log = append(current.Log, LogEntry{Time: finished, Message: fmt.Sprintf("Error saving run: %v", err)})
+		}
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"id": current.ID, "url": fmt.Sprintf("/api/runs/%d", current.ID)})
+}
+
+func (s *server) listRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]run, len(s.runs))
+	for i, current := range s.runs {
+		list[i] = *current
+		list[i].Results, list[i].Log = nil, nil
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// findRun returns the run with the id in the path, writing a 404 when there
+// isn't one. The server's mutex must be held.
+func (s *server) findRun(w http.ResponseWriter, id string) *run {
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 || n > len(s.runs) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no run %s", id))
+		return nil
+	}
+	return s.runs[n-1]
+}
+
+func (s *server) getRun(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current := s.findRun(w, r.PathValue("id")); current != nil {
+		writeJSON(w, http.StatusOK, current)
+	}
+}
+
+// streamLog sends the run's log entries as server-sent events, starting with
+// the ones already logged, progress events with completed and total as they
+// change, and a done event with the run once it finishes.
+func (s *server) streamLog(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	current := s.findRun(w, r.PathValue("id"))
+	s.mu.Unlock()
+	if current == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming isn't supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	sent := 0
+	progress := [2]int{-1, -1}
+	for {
+		s.mu.Lock()
+		entries := current.Log[sent:]
+		latest := [2]int{current.Completed, current.Total}
+		finished := current.Status != StatusRunning
+		updated := current.updated
+		var summary run
+		if finished {
+			summary = *current
+			summary.Results, summary.Log = nil, nil
+		}
+		s.mu.Unlock()
+
+		for _, entry := range entries {
+			data, _ := json.Marshal(entry)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		sent += len(entries)
+		if latest != progress {
+			progress = latest
+			fmt.Fprintf(w, "event: progress\ndata: {\"completed\":%d,\"total\":%d}\n\n", latest[0], latest[1])
+		}
+		if finished {
+			data, _ := json.Marshal(summary)
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) diffRun(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.findRun(w, r.PathValue("id"))
+	if current == nil {
+		return
+	}
+	var against *run
+	if id := r.URL.Query().Get("against"); id != "" {
+		if against = s.findRun(w, id); against == nil {
+			return
+		}
+	} else {
+		for i := current.ID - 2; i >= 0 && against == nil; i-- {
+			if s.runs[i].Status == StatusDone && sameRequest(s.runs[i], current) {
+				against = s.runs[i]
+			}
+		}
+		if against == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no earlier run of %s with the same topics and args to compare with", current.Script))
+			return
+		}
+	}
+	changes := DiffResults(against.Results, current.Results)
+	if changes == nil {
+		changes = []Change{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"against": against.ID, "changes": changes})
+}
+
+// sameRequest reports whether two runs ran the same script for the same
+// topics with the same args, so their results can be compared.
+func sameRequest(a, b *run) bool {
+	return a.Script == b.Script && slices.Equal(a.Topics, b.Topics) && slices.Equal(a.Args, b.Args)
+}
+
+// DiffResults compares two runs' results, matching projects by their path.
+// Changes are sorted by project.
+func DiffResults(before, after []map[string]any) []Change {
+	byProject := func(results []map[string]any) map[string]map[string]any {
+		m := map[string]map[string]any{}
+		for _, result := range results {
+			project, _ := result["Project Path"].(string)
+			m[project] = result
+		}
+		return m
+	}
+	earlier, later := byProject(before), byProject(after)
+
+	var changes []Change
+	for project, a := range later {
+		b, ok := earlier[project]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Project: project, Change: "added", After: a})
+		case !reflect.DeepEqual(a, b):
+			changes = append(changes, Change{Project: project, Change: "changed", Before: b, After: a})
+		}
+	}
+	for project, b := range earlier {
+		if _, ok := later[project]; !ok {
+			changes = append(changes, Change{Project: project, Change: "removed", Before: b})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Project < changes[j].Project })
+	return changes
+}
+
+func (s *server) query(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SQL string `json:"sql"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.SQL) == "" {
+		writeError(w, http.StatusBadRequest, errors.New(`expected {"sql": "..."}`))
+		return
+	}
+	if _, err := os.Stat(s.opts.DBPath); errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no results database at %s", s.opts.DBPath))
+		return
+	}
+	db, err := store.OpenReadOnly(s.opts.DBPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	summary, err := store.Query(db, req.SQL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rows := summary.Rows
+	if rows == nil {
+		rows = []outputs.Row{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"columns": summary.Columns, "rows": rows})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
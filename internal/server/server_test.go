@@ -0,0 +1,351 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+	"github.com/wcatron/query-projects/internal/store"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "results.db")
+	db, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`CREATE TABLE deps (name TEXT, count INTEGER); INSERT INTO deps VALUES ('react', 2)`)
+	db.Close()
+	return startTestServer(t, dbPath)
+}
+
+// startTestServer serves a fake workspace with the results database at
+// dbPath. Runs of scripts/slow.ts wait until the test ends.
+func startTestServer(t *testing.T, dbPath string) *httptest.Server {
+	t.Helper()
+	info := outputs.ScriptInfo{Path: "scripts/node.ts", Output: "text"}
+	release := make(chan struct{})
+	handler, err := New(Options{
+		Token:  "secret",
+		DBPath: dbPath,
+		Projects: func() (*projects.ProjectsJSON, error) {
+			return &projects.ProjectsJSON{Projects: []projects.Project{
+				{Name: "a", Path: "./projects/a", Topics: []string{"web", "api"}},
+				{Name: "b", Path: "./projects/b", Topics: []string{"web"}},
+			}}, nil
+		},
+		Scripts: func() ([]outputs.ScriptInfo, error) {
+			return []outputs.ScriptInfo{info, {Path: "scripts/slow.ts", Output: "text"}}, nil
+		},
+		Run: func(req RunRequest, progress func(completed, total int), log func(project, message string)) (outputs.ScriptInfo, []outputs.Result, error) {
+			progress(1, 2)
+			log("./projects/a", "halfway")
+			if req.Script == "scripts/slow.ts" {
+				<-release
+			}
+			progress(2, 2)
+			return info, []outputs.Result{{ProjectPath: "./projects/a", Status: "Success", Rows: []outputs.Row{{"v20"}}}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	// Let slow runs finish saving before the database is removed
+	t.Cleanup(func() {
+		close(release)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			var list []run
+			request(t, server, http.MethodGet, "/api/runs", "", &list)
+			if !slices.ContainsFunc(list, func(r run) bool { return r.Status == StatusRunning }) {
+				return
+			}
+		}
+	})
+	return server
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, body string, into any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if into != nil {
+		if err := json.Unmarshal(data, into); err != nil {
+			t.Fatalf("%s %s: %v\n%s", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuthorization(t *testing.T) {
+	server := newTestServer(t)
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/projects", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", header, resp.StatusCode)
+		}
+	}
+}
+
+func TestProjectsAndScripts(t *testing.T) {
+	server := newTestServer(t)
+
+	var matched []projects.Project
+	if status := request(t, server, http.MethodGet, "/api/projects?topics=web,-api", "", &matched); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if len(matched) != 1 || matched[0].Name != "b" {
+		t.Errorf("Expected project b, got %+v", matched)
+	}
+
+	for _, query := range []string{"web,%2Bapi", "web,+api", "web,%20%2Bapi"} {
+		matched = nil
+		request(t, server, http.MethodGet, "/api/projects?topics="+query, "", &matched)
+		if len(matched) != 1 || matched[0].Name != "a" {
+			t.Errorf("Expected project a for %s, got %+v", query, matched)
+		}
+	}
+
+	var scriptInfos []outputs.ScriptInfo
+	request(t, server, http.MethodGet, "/api/scripts", "", &scriptInfos)
+	if len(scriptInfos) != 2 || scriptInfos[0].Path != "scripts/node.ts" {
+		t.Errorf("Unexpected scripts %+v", scriptInfos)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	server := newTestServer(t)
+
+	var started struct{ ID int }
+	if status := request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts"}`, &started); status != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", status)
+	}
+	var slow struct{ ID int }
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/slow.ts"}`, &slow)
+
+	done := waitForRun(t, server, 1)
+	want := []map[string]any{{"Project Path": "./projects/a", "Status": "Success", "StdOut": "v20"}}
+	if done.Completed != 2 || done.Total != 2 || !reflect.DeepEqual(done.Results, want) {
+		t.Errorf("Unexpected run %+v", done)
+	}
+
+	var pending run
+	request(t, server, http.MethodGet, "/api/runs/2", "", &pending)
+	if pending.Status != StatusRunning || pending.Completed != 1 || pending.Total != 2 {
+		t.Errorf("Expected the slow run to be in progress, got %+v", pending)
+	}
+
+	var list []run
+	request(t, server, http.MethodGet, "/api/runs", "", &list)
+	if len(list) != 2 || list[0].Results != nil {
+		t.Errorf("Expected two runs without results, got %+v", list)
+	}
+
+	if status := request(t, server, http.MethodGet, "/api/runs/9", "", nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing run, got %d", status)
+	}
+	if status := request(t, server, http.MethodPost, "/api/runs", `{}`, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a script, got %d", status)
+	}
+	for _, script := range []string{"/tmp/evil.ts", "scripts/../evil.ts", "https://example.com/evil.ts"} {
+		body := fmt.Sprintf(`{"script": %q}`, script)
+		if status := request(t, server, http.MethodPost, "/api/runs", body, nil); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", script, status)
+		}
+	}
+	list = nil
+	request(t, server, http.MethodGet, "/api/runs", "", &list)
+	if len(list) != 2 {
+		t.Errorf("Expected rejected scripts not to start runs, got %+v", list)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	server := newTestServer(t)
+
+	var result struct {
+		Columns []string
+		Rows    [][]any
+	}
+	if status := request(t, server, http.MethodPost, "/api/query", `{"sql": "SELECT name, count FROM deps"}`, &result); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if !reflect.DeepEqual(result.Columns, []string{"name", "count"}) || !reflect.DeepEqual(result.Rows, [][]any{{"react", 2.0}}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	if status := request(t, server, http.MethodPost, "/api/query", `{"sql": "DELETE FROM deps"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected writes to be rejected, got %d", status)
+	}
+}
+
+func waitForRun(t *testing.T, server *httptest.Server, id int) run {
+	t.Helper()
+	var current run
+	for deadline := time.Now().Add(5 * time.Second); current.Status != StatusDone; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Run didn't finish: %+v", current)
+		}
+		request(t, server, http.MethodGet, fmt.Sprintf("/api/runs/%d", id), "", &current)
+	}
+	return current
+}
+
+func TestLogStream(t *testing.T) {
+	server := newTestServer(t)
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts"}`, nil)
+	waitForRun(t, server, 1)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/runs/1/log", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+	data, _ := io.ReadAll(resp.Body)
+	events := strings.Split(strings.TrimSpace(string(data)), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("Expected a log entry, progress and done, got %q", events)
+	}
+	var entry LogEntry
+	json.Unmarshal([]byte(strings.TrimPrefix(events[0], "data: ")), &entry)
+	if entry.Project != "./projects/a" || entry.Message != "halfway" {
+		t.Errorf("Unexpected log entry %+v", entry)
+	}
+	if events[1] != "event: progress\ndata: {\"completed\":2,\"total\":2}" {
+		t.Errorf("Unexpected progress event %q", events[1])
+	}
+	if !strings.HasPrefix(events[2], "event: done\ndata: {\"id\":1,") || !strings.Contains(events[2], `"status":"done"`) {
+		t.Errorf("Unexpected done event %q", events[2])
+	}
+}
+
+func TestDiff(t *testing.T) {
+	server := newTestServer(t)
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts", "topics": ["web"]}`, nil)
+	waitForRun(t, server, 1)
+	if status := request(t, server, http.MethodGet, "/api/runs/1/diff", "", nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 without an earlier run, got %d", status)
+	}
+
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts", "topics": ["api"]}`, nil)
+	waitForRun(t, server, 2)
+	if status := request(t, server, http.MethodGet, "/api/runs/2/diff", "", nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 without an earlier run of the same topics, got %d", status)
+	}
+
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts", "topics": ["web"]}`, nil)
+	waitForRun(t, server, 3)
+	var diff struct {
+		Against int
+		Changes []Change
+	}
+	if status := request(t, server, http.MethodGet, "/api/runs/3/diff", "", &diff); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if diff.Against != 1 || len(diff.Changes) != 0 {
+		t.Errorf("Expected no changes since run 1, got %+v", diff)
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "results", "results.db")
+	server := startTestServer(t, dbPath)
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts", "args": ["--major"]}`, nil)
+	first := waitForRun(t, server, 1)
+	if first.RunID == 0 {
+		t.Errorf("Expected the results to be written to the runs table, got %+v", first)
+	}
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/slow.ts"}`, nil)
+
+	// A new server picks up the saved runs, as if the first one stopped
+	// during the slow run
+	server = startTestServer(t, dbPath)
+	var list []run
+	request(t, server, http.MethodGet, "/api/runs", "", &list)
+	if len(list) != 2 || list[0].Status != StatusDone || !slices.Equal(list[0].Args, []string{"--major"}) || list[1].Status != StatusFailed {
+		t.Fatalf("Expected the saved runs, got %+v", list)
+	}
+
+	request(t, server, http.MethodPost, "/api/runs", `{"script": "scripts/node.ts", "args": ["--major"]}`, nil)
+	waitForRun(t, server, 3)
+	var diff struct {
+		Against int
+		Changes []Change
+	}
+	if status := request(t, server, http.MethodGet, "/api/runs/3/diff", "", &diff); status != http.StatusOK || diff.Against != 1 {
+		t.Errorf("Expected a diff with run 1 from the earlier server, got %d %+v", status, diff)
+	}
+
+	var result struct{ Rows [][]any }
+	request(t, server, http.MethodPost, "/api/query", `{"sql": "SELECT run_id, project_path, output FROM node_results ORDER BY run_id"}`, &result)
+	want := [][]any{{1.0, "./projects/a", "v20"}, {2.0, "./projects/a", "v20"}}
+	if !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("Expected %v, got %v", want, result.Rows)
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	before := []map[string]any{
+		{"Project Path": "./projects/a", "Status": "Success", "StdOut": "v18"},
+		{"Project Path": "./projects/b", "Status": "Success", "StdOut": "v20"},
+		{"Project Path": "./projects/c", "Status": "Success", "StdOut": "v20"},
+	}
+	after := []map[string]any{
+		{"Project Path": "./projects/d", "Status": "Success", "StdOut": "v22"},
+		{"Project Path": "./projects/b", "Status": "Success", "StdOut": "v20"},
+		{"Project Path": "./projects/a", "Status": "Success", "StdOut": "v20"},
+	}
+	want := []Change{
+		{Project: "./projects/a", Change: "changed", Before: before[0], After: after[2]},
+		{Project: "./projects/c", Change: "removed", Before: before[2]},
+		{Project: "./projects/d", Change: "added", After: after[0]},
+	}
+	if got := DiffResults(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	server := newTestServer(t)
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected %s to be served without a token, got %d", path, resp.StatusCode)
+		}
+	}
+}
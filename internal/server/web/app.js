@@ -0,0 +1,377 @@
+// Dashboard for query-projects serve. Every call goes through the JSON API
+// with the token kept in localStorage.
+(function () {
+  const $ = (id) => document.getElementById(id);
+  let token = localStorage.getItem("query-projects-token") || "";
+
+  // A link printed by serve can carry the token in the fragment
+  const fromHash = new URLSearchParams(location.hash.slice(1)).get("token");
+  if (fromHash) {
+    token = fromHash;
+    localStorage.setItem("query-projects-token", token);
+    history.replaceState(null, "", location.pathname);
+  }
+
+  class Unauthorized extends Error {}
+
+  async function api(path, body) {
+    const options = { headers: { Authorization: "Bearer " + token } };
+    if (body !== undefined) {
+      options.method = "POST";
+      options.headers["Content-Type"] = "application/json";
+      options.body = JSON.stringify(body);
+    }
+    const response = await fetch(path, options);
+    if (response.status === 401) {
+      showTokenForm("That token was rejected.");
+      throw new Unauthorized();
+    }
+    if (path.endsWith("/log")) {
+      return response;
+    }
+    const data = await response.json();
+    if (!response.ok) {
+      throw new Error(data.error || response.statusText);
+    }
+    return data;
+  }
+
+  function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [key, value] of Object.entries(attrs || {})) {
+      if (key === "onclick") {
+        node.addEventListener("click", value);
+      } else {
+        node.setAttribute(key, value);
+      }
+    }
+    for (const child of children) {
+      if (child !== null && child !== undefined) {
+        node.append(child instanceof Node ? child : String(child));
+      }
+    }
+    return node;
+  }
+
+  function badge(status) {
+    return el("span", { class: "badge " + String(status).toLowerCase() }, status);
+  }
+
+  function format(value) {
+    if (value === null || value === undefined) return "";
+    if (typeof value === "object") return JSON.stringify(value, null, 2);
+    return String(value);
+  }
+
+  // renderTable fills table with columns and rows of values or nodes. filter
+  // hides rows whose text doesn't include it.
+  function renderTable(table, columns, rows, filter) {
+    const needle = (filter || "").toLowerCase();
+    table.replaceChildren(el("thead", null, el("tr", null, ...columns.map((c) => el("th", null, c)))));
+    const body = el("tbody");
+    for (const row of rows) {
+      const cells = row.map((value) => el("td", null, value instanceof Node ? value : format(value)));
+      const tr = el("tr", null, ...cells);
+      if (needle && !tr.textContent.toLowerCase().includes(needle)) continue;
+      body.append(tr);
+    }
+    table.append(body);
+  }
+
+  // Views
+
+  function showTokenForm(message) {
+    token = "";
+    localStorage.removeItem("query-projects-token");
+    document.querySelectorAll("main > section").forEach((s) => (s.hidden = true));
+    $("token-form").hidden = false;
+    $("sign-out").hidden = true;
+    $("token-error").textContent = message || "";
+  }
+
+  function showView() {
+    if (!token) {
+      showTokenForm();
+      return;
+    }
+    const view = location.hash.slice(1) || "projects";
+    $("token-form").hidden = true;
+    $("sign-out").hidden = false;
+    document.querySelectorAll("main > section").forEach((s) => (s.hidden = s.id !== view));
+    document.querySelectorAll("nav a").forEach((a) => a.classList.toggle("active", a.dataset.view === view));
+    const load = { projects: loadProjects, scripts: loadScripts, runs: loadRuns }[view];
+    if (load) {
+      load().catch(report);
+    }
+  }
+
+  function report(err) {
+    if (!(err instanceof Unauthorized)) {
+      console.error(err);
+      alert(err.message);
+    }
+  }
+
+  // Projects with topic facets. A facet cycles between required, excluded and
+  // unused, like run --topics with + and -.
+
+  let allProjects = [];
+  const facets = {};
+
+  async function loadProjects() {
+    allProjects = await api("/api/projects");
+    renderProjects();
+  }
+
+  function renderProjects() {
+    const matches = allProjects.filter((p) => {
+      const topics = p.topics || [];
+      return Object.entries(facets).every(([topic, state]) =>
+        state === "required" ? topics.includes(topic) : !topics.includes(topic));
+    });
+
+    const counts = {};
+    for (const p of matches) {
+      for (const topic of p.topics || []) counts[topic] = (counts[topic] || 0) + 1;
+    }
+    const topics = [...new Set(allProjects.flatMap((p) => p.topics || []))].sort();
+    $("facets").replaceChildren(...topics.map((topic) =>
+      el("li", {
+        class: facets[topic] || "",
+        onclick: () => {
+          facets[topic] = { undefined: "required", required: "excluded" }[facets[topic]];
+          if (!facets[topic]) delete facets[topic];
+          renderProjects();
+        },
+      }, el("span", null, topic), el("span", { class: "hint" }, counts[topic] || 0))));
+
+    $("project-count").textContent = matches.length + " of " + allProjects.length + " projects";
+    renderTable($("project-table"), ["Name", "Path", "Topics", "Repository"], matches.map((p) => [
+      p.name,
+      p.path,
+      el("span", null, ...(p.topics || []).map((t) => el("span", { class: "topic" }, t))),
+      p.repoUrl || "",
+    ]), $("project-filter").value);
+  }
+
+  // Script catalog
+
+  async function loadScripts() {
+    const scripts = await api("/api/scripts");
+    renderTable($("script-table"), ["Script", "Version", "Output", "Columns", ""], scripts.map((s) => [
+      s.path,
+      s.version || "",
+      s.output || "text",
+      (s.columns || []).join(", "),
+      el("button", { class: "secondary", onclick: () => {
+        launchScript = s.path;
+        location.hash = "runs";
+      } }, "Run"),
+    ]));
+    return scripts;
+  }
+
+  // Runs: the launcher, a live view of one run, diffs and history
+
+  let shownRun = null;
+  let launchScript = "";
+
+  async function loadRuns() {
+    const [scripts, runs] = await Promise.all([api("/api/scripts"), api("/api/runs")]);
+    const select = $("run-script");
+    const selected = launchScript || select.value;
+    launchScript = "";
+    select.replaceChildren(...scripts.map((s) => el("option", { value: s.path }, s.path)));
+    if (selected) select.value = selected;
+
+    renderTable($("history-table"), ["Run", "Script", "Status", "Started", "Projects", ""], runs.slice().reverse().map((r) => [
+      "#" + r.id,
+      r.script + describe(r),
+      badge(r.status),
+      new Date(r.started).toLocaleString(),
+      r.completed + " / " + r.total,
+      el("span", null,
+        el("button", { class: "secondary", onclick: () => showRun(r.id) }, "View"), " ",
+        el("button", { class: "secondary", onclick: () => showDiff(r.id) }, "Diff with previous")),
+    ]));
+  }
+
+  // describe shows the topics and args a run was started with, which diffs
+  // with the previous run match on along with the script.
+  function describe(run) {
+    const parts = [];
+    if (run.topics && run.topics.length) parts.push("topics " + run.topics.join(","));
+    if (run.args && run.args.length) parts.push(run.args.join(" "));
+    return parts.length ? " (" + parts.join(", ") + ")" : "";
+  }
+
+  $("launcher").addEventListener("submit", async (event) => {
+    event.preventDefault();
+    const topics = $("run-topics").value.split(",").map((t) => t.trim()).filter(Boolean);
+    const args = $("run-args").value.split(/\s+/).filter(Boolean);
+    try {
+      const started = await api("/api/runs", { script: $("run-script").value, topics, args });
+      showRun(started.id);
+    } catch (err) {
+      report(err);
+    }
+  });
+
+  async function showRun(id) {
+    shownRun = id;
+    $("diff-view").hidden = true;
+    $("run-view").hidden = false;
+    $("run-results").hidden = true;
+    $("run-log").replaceChildren();
+    const run = await api("/api/runs/" + id);
+    $("run-title").replaceChildren("Run #" + id + " ", el("code", null, run.script), " ", badge(run.status));
+    updateProgress(run);
+    loadRuns().catch(report);
+
+    // Log entries arrive as server-sent events until the run is done
+    const response = await api("/api/runs/" + id + "/log");
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done || shownRun !== id) break;
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf("\n\n")) >= 0) {
+        const event = parseEvent(buffer.slice(0, end));
+        buffer = buffer.slice(end + 2);
+        if (event.name === "done") {
+          await finishRun(id);
+        } else if (event.name === "progress") {
+          updateProgress(event.data);
+        } else {
+          appendLog(event.data);
+        }
+      }
+    }
+    reader.cancel();
+  }
+
+  function parseEvent(text) {
+    const event = { name: "message", data: null };
+    for (const line of text.split("\n")) {
+      if (line.startsWith("event: ")) event.name = line.slice(7);
+      if (line.startsWith("data: ")) event.data = JSON.parse(line.slice(6));
+    }
+    return event;
+  }
+
+  function appendLog(entry) {
+    const log = $("run-log");
+    log.append(
+      el("span", { class: "time" }, new Date(entry.time).toLocaleTimeString()), " ",
+      el("span", { class: "project" }, entry.project), " ",
+      entry.message, "\n");
+    log.scrollTop = log.scrollHeight;
+  }
+
+  function updateProgress(run) {
+    const percent = run.total ? Math.round((100 * run.completed) / run.total) : 0;
+    $("run-progress").style.width = percent + "%";
+    $("run-status").textContent = run.completed + " of " + run.total + " projects" + (run.error ? ", " + run.error : "");
+  }
+
+  let shownResults = [];
+
+  async function finishRun(id) {
+    const run = await api("/api/runs/" + id);
+    if (shownRun !== id) return;
+    $("run-title").lastChild.replaceWith(badge(run.status));
+    updateProgress(run);
+    shownResults = resultRows(run.results || []);
+    const statuses = [...new Set(shownResults.rows.map((r) => r[1]))].sort();
+    $("result-status").replaceChildren(el("option", { value: "" }, "All statuses"),
+      ...statuses.map((s) => el("option", { value: s }, s)));
+    $("run-results").hidden = false;
+    renderResults();
+    loadRuns().catch(report);
+  }
+
+  // resultRows flattens results into a table, one row per csv or json row.
+  function resultRows(results) {
+    const keys = [];
+    for (const result of results) {
+      for (const row of Array.isArray(result.Output) ? result.Output : []) {
+        for (const key of Object.keys(row)) if (!keys.includes(key)) keys.push(key);
+      }
+    }
+    const columns = ["Project", "Status", ...(keys.length ? keys : ["Output"])];
+    const rows = [];
+    for (const result of results) {
+      const base = [result["Project Path"], result.Status];
+      if (keys.length && Array.isArray(result.Output) && result.Output.length) {
+        for (const row of result.Output) rows.push([...base, ...keys.map((k) => row[k])]);
+      } else {
+        rows.push([...base, result.StdOut || result.StdErr || "", ...keys.slice(1).map(() => "")]);
+      }
+    }
+    return { columns, rows };
+  }
+
+  function renderResults() {
+    const status = $("result-status").value;
+    const rows = shownResults.rows
+      .filter((r) => !status || r[1] === status)
+      .map((r) => [r[0], badge(r[1]), ...r.slice(2)]);
+    renderTable($("result-table"), shownResults.columns, rows, $("result-filter").value);
+  }
+
+  async function showDiff(id) {
+    try {
+      const diff = await api("/api/runs/" + id + "/diff");
+      shownRun = null;
+      $("run-view").hidden = true;
+      $("diff-view").hidden = false;
+      $("diff-title").textContent = "Run #" + id + " compared with #" + diff.against +
+        (diff.changes.length ? "" : ", no changes");
+      const output = (result) => result && (result.Output || result.StdOut || result.StdErr || result.Status);
+      renderTable($("diff-table"), ["Project", "Change", "Before", "After"], diff.changes.map((c) => [
+        c.project, badge(c.change), output(c.before), output(c.after),
+      ]));
+    } catch (err) {
+      report(err);
+    }
+  }
+
+  // Read-only SQL against the results database
+
+  let queryResult = { columns: [], rows: [] };
+
+  $("query-form").addEventListener("submit", async (event) => {
+    event.preventDefault();
+    $("query-error").textContent = "";
+    try {
+      queryResult = await api("/api/query", { sql: $("sql").value });
+    } catch (err) {
+      if (!(err instanceof Unauthorized)) $("query-error").textContent = err.message;
+      queryResult = { columns: [], rows: [] };
+    }
+    renderQuery();
+  });
+
+  function renderQuery() {
+    renderTable($("query-table"), queryResult.columns || [], queryResult.rows, $("query-filter").value);
+  }
+
+  // Wiring
+
+  $("token-form").addEventListener("submit", (event) => {
+    event.preventDefault();
+    token = $("token").value.trim();
+    localStorage.setItem("query-projects-token", token);
+    $("token").value = "";
+    showView();
+  });
+  $("sign-out").addEventListener("click", () => showTokenForm());
+  $("project-filter").addEventListener("input", renderProjects);
+  $("result-filter").addEventListener("input", renderResults);
+  $("result-status").addEventListener("change", renderResults);
+  $("query-filter").addEventListener("input", renderQuery);
+  window.addEventListener("hashchange", showView);
+  showView();
+})();
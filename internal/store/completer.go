@@ -0,0 +1,54 @@
+package store
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+var sqlKeywords = []string{
+	"SELECT", "FROM", "WHERE", "GROUP BY", "ORDER BY", "HAVING", "LIMIT", "JOIN", "LEFT JOIN",
+	"ON", "AND", "OR", "NOT", "AS", "DISTINCT", "COUNT", "SUM", "MIN", "MAX", "AVG", "LIKE",
+	"IN", "IS NULL", "DESC", "ASC", "WITH", "CASE", "WHEN", "THEN", "ELSE", "END",
+}
+
+// Completer completes SQL keywords, table names and column names in the query
+// REPL.
+type Completer struct {
+	words []string
+}
+
+// NewCompleter collects the tables and columns of db for completion.
+func NewCompleter(db *sql.DB) *Completer {
+	words := slices.Clone(sqlKeywords)
+	tables, _ := Tables(db)
+	for _, table := range tables {
+		words = append(words, table)
+		columns, _ := Columns(db, table)
+		for _, column := range columns {
+			if !slices.Contains(words, column) {
+				words = append(words, column)
+			}
+		}
+	}
+	words = append(words, ".tables", ".schema", ".export", "exit")
+	return &Completer{words: words}
+}
+
+// Complete replaces the last word of line with each word it prefixes,
+// ignoring case.
+func (c *Completer) Complete(line string) []string {
+	start := strings.LastIndexAny(line, " \t,(") + 1
+	prefix := strings.ToLower(line[start:])
+	if prefix == "" {
+		return nil
+	}
+
+	var completions []string
+	for _, word := range c.words {
+		if strings.HasPrefix(strings.ToLower(word), prefix) {
+			completions = append(completions, line[:start]+word)
+		}
+	}
+	return completions
+}
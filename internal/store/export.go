@@ -0,0 +1,146 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+// Manifest describes the files written by an export so warehouses and BI tools
+// can pick them up without guessing at their schema.
+type Manifest struct {
+	Database  string          `json:"database"`
+	Generated time.Time       `json:"generated"`
+	Tables    []TableManifest `json:"tables"`
+}
+
+// TableManifest describes one exported table.
+type TableManifest struct {
+	Name    string           `json:"name"`
+	File    string           `json:"file"` // Relative to the manifest
+	Rows    int              `json:"rows"`
+	Columns []ColumnManifest `json:"columns"`
+}
+
+// ColumnManifest is a column in the order of the SQLite table. Type is the
+// Parquet type, INT64, DOUBLE or STRING, and every column is optional.
+type ColumnManifest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ExportParquet writes table to a snappy compressed Parquet file at path.
+// SQLite columns can hold values of any type, so each column's type comes
+// from its values: INT64 when every value is an integer, DOUBLE when they are
+// all numbers and STRING otherwise. Parquet orders columns by name, the
+// returned manifest keeps the table's order.
+func ExportParquet(db *sql.DB, table string, path string) (TableManifest, error) {
+	summary, err := Query(db, "SELECT * FROM "+QuoteIdent(table))
+	if err != nil {
+		return TableManifest{}, fmt.Errorf("read %s: %w", table, err)
+	}
+
+	manifest := TableManifest{Name: table, File: filepath.Base(path), Rows: len(summary.Rows)}
+	group := parquet.Group{}
+	types := make([]string, len(summary.Columns))
+	for i, column := range summary.Columns {
+		types[i] = parquetType(summary.Rows, i)
+		switch types[i] {
+		case "INT64":
+			group[column] = parquet.Optional(parquet.Int(64))
+		case "DOUBLE":
+			group[column] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		default:
+			group[column] = parquet.Optional(parquet.String())
+		}
+		manifest.Columns = append(manifest.Columns, ColumnManifest{Name: column, Type: types[i]})
+	}
+	schema := parquet.NewSchema(table, group)
+
+	// Leaf columns are indexed in the schema's order
+	var leaves []string
+	for _, field := range schema.Fields() {
+		leaves = append(leaves, field.Name())
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return TableManifest{}, fmt.Errorf("create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := parquet.NewWriter(file, schema, parquet.Compression(&parquet.Snappy))
+	rows := make([]parquet.Row, len(summary.Rows))
+	for r, row := range summary.Rows {
+		rows[r] = make(parquet.Row, len(leaves))
+		for i, column := range summary.Columns {
+			index := slices.Index(leaves, column)
+			if row[i] == nil {
+				rows[r][index] = parquet.Value{}.Level(0, 0, index)
+			} else {
+				rows[r][index] = parquetValue(row[i], types[i]).Level(0, 1, index)
+			}
+		}
+	}
+	if _, err := writer.WriteRows(rows); err != nil {
+		return TableManifest{}, fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := writer.Close(); err != nil {
+		return TableManifest{}, fmt.Errorf("write %s: %w", path, err)
+	}
+	return manifest, file.Close()
+}
+
+// parquetType picks the Parquet type for the values of a column, ignoring
+// nulls. Columns without values are strings.
+func parquetType(rows []outputs.Row, column int) string {
+	t := "STRING"
+	for _, row := range rows {
+		switch row[column].(type) {
+		case nil:
+		case int64:
+			if t == "STRING" {
+				t = "INT64"
+			}
+		case float64:
+			t = "DOUBLE"
+		default:
+			return "STRING"
+		}
+	}
+	return t
+}
+
+func parquetValue(value any, parquetType string) parquet.Value {
+	switch parquetType {
+	case "INT64":
+		return parquet.ValueOf(value.(int64))
+	case "DOUBLE":
+		if n, ok := value.(int64); ok {
+			return parquet.ValueOf(float64(n))
+		}
+		return parquet.ValueOf(value.(float64))
+	default:
+		return parquet.ValueOf(outputs.FormatValue(value))
+	}
+}
+
+// WriteManifest writes manifest.json to dir.
+func WriteManifest(dir string, manifest Manifest) (string, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal manifest: %w", err)
+	}
+	path := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("write manifest: %w", err)
+	}
+	return path, nil
+}
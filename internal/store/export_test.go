@@ -0,0 +1,59 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestExportParquet(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE deps (name TEXT, count INTEGER, ratio REAL, extra); INSERT INTO deps VALUES ('react', 3, 0.5, 1), ('vue', NULL, 1, 'x')`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "deps.parquet")
+	manifest, err := ExportParquet(db, "deps", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TableManifest{Name: "deps", File: "deps.parquet", Rows: 2, Columns: []ColumnManifest{
+		{"name", "STRING"}, {"count", "INT64"}, {"ratio", "DOUBLE"}, {"extra", "STRING"},
+	}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Expected manifest %+v, got %+v", want, manifest)
+	}
+
+	type dep struct {
+		Name  string   `parquet:"name"`
+		Count *int64   `parquet:"count"`
+		Ratio *float64 `parquet:"ratio"`
+		Extra string   `parquet:"extra"`
+	}
+	rows, err := parquet.ReadFile[dep](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, half, one := int64(3), 0.5, 1.0
+	wantRows := []dep{{"react", &three, &half, "1"}, {"vue", nil, &one, "x"}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("Expected rows %+v, got %+v", wantRows, rows)
+	}
+
+	manifestPath, err := WriteManifest(dir, Manifest{Database: "results.db", Tables: []TableManifest{manifest}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(manifestPath); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// projectKey and packageKey are the columns holding each record's project and
+// workspace package, "Project Path" and "Package" in the json output of run.
+const (
+	projectKey = "Project_Path"
+	packageKey = "Package"
+)
+
+// jsonRecord is an object to insert along with the values of its table's
+// fixed columns and the project and package of its top level record.
+type jsonRecord struct {
+	object  map[string]any
+	fixed   []any
+	project []any
+}
+
+// LoadJSON loads a json or ndjson results file into a table named after the
+// file. A json file holds an array of objects, as written by run --output json,
+// or a single object, and an ndjson file an object per line. Nested objects are
+// flattened into columns joined with _, so {"Output": {"version": "1"}} becomes
+// Output_version. Arrays of objects go to a child table named
+// <table>_<column>, each row keeping its parent's rowid, Project_Path, Package
+// and index in the array. Other arrays are stored as JSON for use with
+// json_each. Records loaded from the same file before are skipped, and with
+// replace the table and its child tables are dropped first. The file is loaded
+// in a single transaction.
+func LoadJSON(db *sql.DB, path string, replace bool) error {
+	objects, err := readJSONObjects(path)
+	if err != nil {
+		return err
+	}
+
+	table := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	hash := rowHasher(path)
+	records := make([]jsonRecord, len(objects))
+	for i, object := range objects {
+		records[i] = jsonRecord{object: object, fixed: []any{hash(object)}}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if err := dropLoadedTable(tx, table); err != nil {
+			return err
+		}
+	}
+	tables, inserted, err := insertJSONRecords(tx, table, []string{hashColumn}, records)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	printLoaded(path, tables, inserted, len(records))
+	return nil
+}
+
+// readJSONObjects decodes every object in a json or ndjson file. Values that
+// aren't objects are wrapped as {"value": v}.
+func readJSONObjects(path string) ([]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	var values []any
+	if ext := filepath.Ext(path); ext == ".ndjson" || ext == ".jsonl" {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			value, err := decodeJSON(scanner.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("read line %d of %s: %w", line, path, err)
+			}
+			values = append(values, value)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+	} else {
+		value, err := decodeJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		if array, ok := value.([]any); ok {
+			values = array
+		} else {
+			values = []any{value}
+		}
+	}
+
+	objects := make([]map[string]any, len(values))
+	for i, value := range values {
+		object, ok := value.(map[string]any)
+		if !ok {
+			object = map[string]any{"value": value}
+		}
+		objects[i] = object
+	}
+	return objects, nil
+}
+
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the first JSON value")
+	}
+	return value, nil
+}
+
+// insertJSONRecords flattens records into table, then inserts the arrays of
+// objects of new records into child tables. fixed names the columns every
+// record has a value for, the content hash for top level records and the
+// parent for child records. It returns the tables written to and the number of
+// records inserted.
+func insertJSONRecords(tx *sql.Tx, table string, fixed []string, records []jsonRecord) ([]string, int, error) {
+	// Columns of the table itself win over record keys of the same name, and
+	// SQLite compares column names without case
+	dedupe := slices.Equal(fixed, []string{hashColumn})
+	reserved := append(slices.Clone(fixed), "qp_created_at")
+	var columns []string
+	values := make([]map[string]any, len(records))
+	children := make([]map[string][]map[string]any, len(records))
+	var childColumns []string
+	for i, record := range records {
+		values[i] = map[string]any{}
+		children[i] = map[string][]map[string]any{}
+		flattenJSON("", record.object, values[i], children[i])
+		for _, column := range slices.Sorted(maps.Keys(values[i])) {
+			if !containsFold(columns, column) && !containsFold(reserved, column) {
+				columns = append(columns, column)
+			}
+		}
+		for _, column := range slices.Sorted(maps.Keys(children[i])) {
+			if !slices.Contains(childColumns, column) {
+				childColumns = append(childColumns, column)
+			}
+		}
+	}
+
+	types := make([]string, len(columns))
+	for i, column := range columns {
+		for _, v := range values {
+			types[i] = widenType(types[i], jsonValueType(v[column]))
+		}
+		if types[i] == "" {
+			types[i] = "TEXT"
+		}
+	}
+
+	if dedupe {
+		if err := ensureTable(tx, table, []string{createdAtColumn}, columns, types); err != nil {
+			return nil, 0, err
+		}
+		if err := ensureHashColumn(tx, table); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		defs := []string{`"parent_id" INTEGER NOT NULL`, `"` + projectKey + `" TEXT`, `"` + packageKey + `" TEXT`, `"idx" INTEGER NOT NULL`, createdAtColumn}
+		if err := ensureTable(tx, table, defs, columns, types); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	stmt, err := prepareInsert(tx, table, append(slices.Clone(fixed), columns...), dedupe)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	childRecords := map[string][]jsonRecord{}
+	for i, record := range records {
+		args := slices.Clone(record.fixed)
+		for j, column := range columns {
+			args = append(args, convertValue(values[i][column], types[j]))
+		}
+		res, err := stmt.Exec(args...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("insert row in %s: %w", table, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			// Already loaded along with its children
+			continue
+		}
+		inserted++
+		rowID, err := res.LastInsertId()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		project := record.project
+		if project == nil {
+			project = []any{values[i][projectKey], values[i][packageKey]}
+		}
+		for column, objects := range children[i] {
+			for index, object := range objects {
+				childRecords[column] = append(childRecords[column], jsonRecord{
+					object:  object,
+					fixed:   append(append([]any{rowID}, project...), index),
+					project: project,
+				})
+			}
+		}
+	}
+
+	tables := []string{table}
+	childFixed := []string{"parent_id", projectKey, packageKey, "idx"}
+	for _, column := range childColumns {
+		if len(childRecords[column]) == 0 {
+			continue
+		}
+		childTables, _, err := insertJSONRecords(tx, table+"_"+column, childFixed, childRecords[column])
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := recordChildTable(tx, table, table+"_"+column); err != nil {
+			return nil, 0, err
+		}
+		tables = append(tables, childTables...)
+	}
+	return tables, inserted, nil
+}
+
+// flattenJSON adds the scalar and array values of object to values, keyed by
+// their path joined with _. Non-empty arrays of objects are added to children
+// instead.
+func flattenJSON(prefix string, object map[string]any, values map[string]any, children map[string][]map[string]any) {
+	for key, value := range object {
+		column := strings.ReplaceAll(strings.TrimSpace(key), " ", "_")
+		if prefix != "" {
+			column = prefix + "_" + column
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flattenJSON(column, v, values, children)
+		case []any:
+			if objects, ok := arrayOfObjects(v); ok {
+				children[column] = objects
+			} else {
+				values[column] = v
+			}
+		default:
+			values[column] = v
+		}
+	}
+}
+
+func arrayOfObjects(array []any) ([]map[string]any, bool) {
+	if len(array) == 0 {
+		return nil, false
+	}
+	objects := make([]map[string]any, len(array))
+	for i, value := range array {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		objects[i] = object
+	}
+	return objects, true
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, s) })
+}
+
+// jsonValueType is valueType for decoded JSON, where strings are always TEXT
+// because JSON already says whether a value is a number.
+func jsonValueType(value any) string {
+	if s, ok := value.(string); ok && s != "" {
+		return "TEXT"
+	}
+	return valueType(value)
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestLoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	jsonPath := filepath.Join(dir, "deps.json")
+	os.WriteFile(jsonPath, []byte(`[
+  {"Project Path": "./projects/a", "Package": "web", "Status": "Success", "Output": [
+    {"name": "react", "version": "18.2.0", "meta": {"dev": false}},
+    {"name": "jest", "version": "29.0.0", "meta": {"dev": true}}
+  ]},
+  {"Project Path": "./projects/b", "Status": "Success", "Output": {"node": {"engine": 20}, "tags": ["web", "api"]}}
+]`), 0o644)
+	if err := LoadJSON(db, jsonPath, false); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRows(t, db, `SELECT Project_Path, Status, Output_node_engine, Output_tags FROM deps ORDER BY Project_Path`, []outputs.Row{
+		{"./projects/a", "Success", nil, nil},
+		{"./projects/b", "Success", int64(20), `["web","api"]`},
+	})
+	assertRows(t, db, `SELECT o.Project_Path, o.Package, o.idx, o.name, o.meta_dev FROM deps_Output o JOIN deps d ON d.rowid = o.parent_id ORDER BY o.idx`, []outputs.Row{
+		{"./projects/a", "web", int64(0), "react", int64(0)},
+		{"./projects/a", "web", int64(1), "jest", int64(1)},
+	})
+	assertRows(t, db, `SELECT t.value FROM deps, json_each(deps.Output_tags) t`, []outputs.Row{{"web"}, {"api"}})
+
+	// Loading again skips records already loaded, replacing starts over
+	if err := LoadJSON(db, jsonPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT (SELECT COUNT(*) FROM deps), (SELECT COUNT(*) FROM deps_Output)`, []outputs.Row{{int64(2), int64(2)}})
+	os.WriteFile(jsonPath, []byte(`[{"Project Path": "./projects/c", "Status": "Error"}]`), 0o644)
+	if err := LoadJSON(db, jsonPath, true); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT Project_Path FROM deps`, []outputs.Row{{"./projects/c"}})
+	if tables, _ := Tables(db); slices.Contains(tables, "deps_Output") {
+		t.Errorf("Expected replace to drop deps_Output, got %v", tables)
+	}
+
+	// Replacing a table leaves tables that only share its prefix alone
+	devPath := filepath.Join(dir, "deps_dev.json")
+	os.WriteFile(devPath, []byte(`[{"Project Path": "./projects/a", "items": [{"name": "jest"}]}]`), 0o644)
+	if err := LoadJSON(db, devPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadJSON(db, jsonPath, true); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT d.Project_Path, i.name FROM deps_dev d JOIN deps_dev_items i ON i.parent_id = d.rowid`, []outputs.Row{{"./projects/a", "jest"}})
+
+	ndjsonPath := filepath.Join(dir, "stream.ndjson")
+	os.WriteFile(ndjsonPath, []byte("{\"Project Path\": \"./projects/a\", \"Output\": 1.5, \"QP_Created_At\": \"2026-01-01\"}\n\n{\"Project Path\": \"./projects/b\", \"Output\": 2}\n"), 0o644)
+	if err := LoadJSON(db, ndjsonPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT Project_Path, Output FROM stream ORDER BY Project_Path`, []outputs.Row{
+		{"./projects/a", 1.5}, {"./projects/b", 2.0},
+	})
+
+	os.WriteFile(ndjsonPath, []byte("{\"Project Path\": \"./projects/c\"}\nnot json\n"), 0o644)
+	if err := LoadJSON(db, ndjsonPath, false); err == nil {
+		t.Error("Expected an error for an invalid line")
+	}
+}
+
+func TestLoadJSON_StringsStayText(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	jsonPath := filepath.Join(dir, "versions.json")
+	os.WriteFile(jsonPath, []byte(`[{"Project Path": "./projects/a", "version": "1.20", "code": "007", "count": 3}]`), 0o644)
+	if err := LoadJSON(db, jsonPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT version, code, count FROM versions`, []outputs.Row{{"1.20", "007", int64(3)}})
+}
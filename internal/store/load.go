@@ -0,0 +1,231 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loaded tables record when each row was loaded and a hash of the file it came
+// from and its content, so loading the same file twice doesn't duplicate its
+// rows.
+const (
+	createdAtColumn = `"qp_created_at" TEXT DEFAULT (CURRENT_TIMESTAMP)`
+	hashColumn      = "qp_hash"
+)
+
+// loadedTablesSchema records the child tables LoadJSON created for each table,
+// so replacing a table drops only its own children.
+const loadedTablesSchema = `
+CREATE TABLE IF NOT EXISTS loaded_tables (
+	name TEXT PRIMARY KEY,
+	parent TEXT NOT NULL
+);
+`
+
+// LoadCSV loads a CSV file with a header row into a table named after the
+// file, adding columns the table doesn't have yet. Rows loaded from the same
+// file before are skipped. With replace the table is dropped first. The file is loaded in a
+// single transaction.
+func LoadCSV(db *sql.DB, path string, replace bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	headers, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("read header of %s: %w", path, err)
+	}
+	// sanitize headers: trim and replace spaces with underscores
+	for i, h := range headers {
+		headers[i] = strings.ReplaceAll(strings.TrimSpace(h), " ", "_")
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("read rows of %s: %w", path, err)
+	}
+
+	table := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if err := dropLoadedTable(tx, table); err != nil {
+			return err
+		}
+	}
+	types := make([]string, len(headers))
+	for i := range types {
+		types[i] = "TEXT"
+	}
+	if err := ensureTable(tx, table, []string{createdAtColumn}, headers, types); err != nil {
+		return err
+	}
+	if err := ensureHashColumn(tx, table); err != nil {
+		return err
+	}
+
+	stmt, err := prepareInsert(tx, table, append([]string{hashColumn}, headers...), true)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	hash := rowHasher(path)
+	inserted := 0
+	for _, rec := range records {
+		row := make(map[string]string, len(headers))
+		vals := []any{""}
+		for i, v := range rec {
+			if i < len(headers) {
+				row[headers[i]] = v
+			}
+			vals = append(vals, v)
+		}
+		vals[0] = hash(row)
+		res, err := stmt.Exec(vals...)
+		if err != nil {
+			return fmt.Errorf("insert row in %s: %w", table, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	printLoaded(path, []string{table}, inserted, len(records))
+	return nil
+}
+
+// prepareInsert prepares an insert of columns into table. With ignore, rows
+// that conflict with a unique index, such as the content hash, are skipped.
+func prepareInsert(tx *sql.Tx, table string, columns []string, ignore bool) (*sql.Stmt, error) {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = QuoteIdent(c)
+	}
+	verb := "INSERT"
+	if ignore {
+		verb = "INSERT OR IGNORE"
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`%s INTO %s (%s) VALUES (%s)`, verb,
+		QuoteIdent(table), strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("prepare insert into %s: %w", table, err)
+	}
+	return stmt, nil
+}
+
+// ensureHashColumn adds the content hash column and its unique index to table.
+// Rows loaded before it existed have no hash and are never treated as
+// duplicates.
+func ensureHashColumn(tx *sql.Tx, table string) error {
+	columns, err := Columns(tx, table)
+	if err != nil {
+		return err
+	}
+	if !containsFold(columns, hashColumn) {
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s TEXT`, QuoteIdent(table), hashColumn)); err != nil {
+			return fmt.Errorf("add column %s to %s: %w", hashColumn, table, err)
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)`,
+		QuoteIdent(table+"_"+hashColumn), QuoteIdent(table), hashColumn)); err != nil {
+		return fmt.Errorf("index %s: %w", table, err)
+	}
+	return nil
+}
+
+// rowHasher returns a function hashing the rows of the file at path in order.
+// A row is hashed by the file, its column names and values, and how many
+// identical rows came before it, so reloading a file skips the rows it already
+// loaded while repeated rows within the file are all kept. The same row hashes
+// the same when the file's columns are reordered.
+func rowHasher(path string) func(row any) string {
+	source, err := filepath.Abs(path)
+	if err != nil {
+		source = path
+	}
+	seen := map[string]int{}
+	return func(row any) string {
+		// Map keys are marshalled in sorted order
+		content, _ := json.Marshal(row)
+		occurrence := seen[string(content)]
+		seen[string(content)]++
+		data, _ := json.Marshal([]any{source, string(content), occurrence})
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+// recordChildTable records that LoadJSON created child for the table parent.
+func recordChildTable(tx *sql.Tx, parent string, child string) error {
+	if _, err := tx.Exec(loadedTablesSchema); err != nil {
+		return fmt.Errorf("create loaded_tables table: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO loaded_tables (name, parent) VALUES (?, ?)`, child, parent); err != nil {
+		return fmt.Errorf("record child table %s: %w", child, err)
+	}
+	return nil
+}
+
+// dropLoadedTable drops table along with the child tables LoadJSON recorded for
+// it, and theirs in turn.
+func dropLoadedTable(tx *sql.Tx, table string) error {
+	tables, err := Tables(tx)
+	if err != nil {
+		return err
+	}
+	if containsFold(tables, "loaded_tables") {
+		rows, err := tx.Query(`SELECT name FROM loaded_tables WHERE parent = ?`, table)
+		if err != nil {
+			return err
+		}
+		var children []string
+		for rows.Next() {
+			var child string
+			if err := rows.Scan(&child); err != nil {
+				rows.Close()
+				return err
+			}
+			children = append(children, child)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := dropLoadedTable(tx, child); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM loaded_tables WHERE parent = ?`, table); err != nil {
+			return fmt.Errorf("forget child tables of %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec("DROP TABLE IF EXISTS " + QuoteIdent(table)); err != nil {
+		return fmt.Errorf("drop %s: %w", table, err)
+	}
+	return nil
+}
+
+func printLoaded(path string, tables []string, inserted int, total int) {
+	fmt.Printf("Loaded %d rows from %s into table %s", inserted, path, strings.Join(tables, ", "))
+	if skipped := total - inserted; skipped > 0 {
+		fmt.Printf(", skipped %d already loaded", skipped)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestLoadCSV(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	csvPath := filepath.Join(dir, "deps.csv")
+	os.WriteFile(csvPath, []byte("Project Path,Status,name\n./projects/a,Success,react\n./projects/b,Success,vue\n"), 0o644)
+	if err := LoadCSV(db, csvPath, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The script added a column with a quote in its name and reordered its
+	// columns, rows with the new column are new rows
+	os.WriteFile(csvPath, []byte("Status,Project Path,name,\"it's\"\nSuccess,./projects/a,react,x\nSuccess,./projects/b,vue,\n"), 0o644)
+	if err := LoadCSV(db, csvPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT Project_Path, name, "it's" FROM deps ORDER BY Project_Path, "it's"`, []outputs.Row{
+		{"./projects/a", "react", nil},
+		{"./projects/a", "react", "x"},
+		{"./projects/b", "vue", nil},
+		{"./projects/b", "vue", ""},
+	})
+	if err := LoadCSV(db, csvPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT COUNT(*) FROM deps`, []outputs.Row{{int64(4)}})
+
+	if err := LoadCSV(db, csvPath, true); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT COUNT(*) FROM deps`, []outputs.Row{{int64(2)}})
+
+	// Identical rows in one file are all kept, and only skipped when the file
+	// is loaded again
+	os.WriteFile(csvPath, []byte("Project Path,Status,name\n./projects/c,Success,react\n./projects/c,Success,react\n"), 0o644)
+	for range 2 {
+		if err := LoadCSV(db, csvPath, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertRows(t, db, `SELECT COUNT(*) FROM deps WHERE Project_Path = './projects/c'`, []outputs.Row{{int64(2)}})
+	otherPath := filepath.Join(dir, "other", "deps.csv")
+	os.MkdirAll(filepath.Dir(otherPath), 0o755)
+	os.WriteFile(otherPath, []byte("Project Path,Status,name\n./projects/c,Success,react\n"), 0o644)
+	if err := LoadCSV(db, otherPath, false); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT COUNT(*) FROM deps WHERE Project_Path = './projects/c'`, []outputs.Row{{int64(3)}})
+
+	// A malformed file loads nothing
+	os.WriteFile(csvPath, []byte("Project Path,Status\n./projects/c,Success\n./projects/d,Success,extra\n"), 0o644)
+	if err := LoadCSV(db, csvPath, false); err == nil {
+		t.Error("Expected an error for a row with too many fields")
+	}
+	assertRows(t, db, `SELECT COUNT(*) FROM deps`, []outputs.Row{{int64(5)}})
+}
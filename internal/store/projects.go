@@ -0,0 +1,128 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+// projectsSchema mirrors projects.json. projects.path matches the project_path
+// column of run --output sqlite tables and the Project_Path column of loaded
+// results, so results can be joined to topics and metadata.
+const projectsSchema = `
+CREATE TABLE IF NOT EXISTS projects (
+	path TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	repo_url TEXT,
+	skip INTEGER NOT NULL DEFAULT 0,
+	metadata JSON
+);
+CREATE TABLE IF NOT EXISTS project_topics (
+	project_path TEXT NOT NULL REFERENCES projects(path) ON DELETE CASCADE,
+	topic TEXT NOT NULL,
+	PRIMARY KEY (project_path, topic)
+);
+CREATE INDEX IF NOT EXISTS project_topics_topic ON project_topics(topic);
+CREATE TABLE IF NOT EXISTS project_metadata (
+	project_path TEXT NOT NULL REFERENCES projects(path) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	value TEXT,
+	PRIMARY KEY (project_path, key)
+);
+CREATE INDEX IF NOT EXISTS project_metadata_key ON project_metadata(key, value);
+`
+
+// LoadProjects replaces the projects, project_topics and project_metadata
+// tables with the contents of projects.json. Metadata is flattened into dot
+// separated keys such as owner.login, with arrays kept as JSON.
+func LoadProjects(db *sql.DB, pj *projects.ProjectsJSON) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(projectsSchema); err != nil {
+		return fmt.Errorf("create projects tables: %w", err)
+	}
+	for _, table := range []string{"project_metadata", "project_topics", "projects"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+
+	for _, p := range pj.Projects {
+		metadata := metadataMap(p.Metadata)
+		var metadataJSON any
+		if metadata != nil {
+			data, err := json.Marshal(metadata)
+			if err != nil {
+				return err
+			}
+			metadataJSON = string(data)
+		}
+		if _, err := tx.Exec(`INSERT INTO projects (path, name, repo_url, skip, metadata) VALUES (?, ?, ?, ?, ?)`,
+			p.Path, p.Name, p.RepoURL, p.Skip, metadataJSON); err != nil {
+			return fmt.Errorf("insert project %s: %w", p.Path, err)
+		}
+
+		for _, topic := range slices.Compact(slices.Sorted(slices.Values(p.Topics))) {
+			if _, err := tx.Exec(`INSERT INTO project_topics (project_path, topic) VALUES (?, ?)`, p.Path, topic); err != nil {
+				return fmt.Errorf("insert topic for %s: %w", p.Path, err)
+			}
+		}
+
+		flat := map[string]string{}
+		flattenMetadata("", metadata, flat)
+		for _, key := range slices.Sorted(maps.Keys(flat)) {
+			if _, err := tx.Exec(`INSERT INTO project_metadata (project_path, key, value) VALUES (?, ?, ?)`, p.Path, key, flat[key]); err != nil {
+				return fmt.Errorf("insert metadata for %s: %w", p.Path, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// metadataMap converts synced metadata, a struct right after sync or a map once
+// read from projects.json, into a map.
+func metadataMap(metadata any) map[string]any {
+	if metadata == nil {
+		return nil
+	}
+	if m, ok := metadata.(map[string]any); ok {
+		return m
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	return m
+}
+
+func flattenMetadata(prefix string, value any, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenMetadata(key, child, out)
+		}
+	case nil:
+		if prefix != "" {
+			out[prefix] = ""
+		}
+	case string:
+		out[prefix] = v
+	default:
+		data, _ := json.Marshal(v)
+		out[prefix] = string(data)
+	}
+}
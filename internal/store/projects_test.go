@@ -0,0 +1,50 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+func TestLoadProjects(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pj := &projects.ProjectsJSON{Projects: []projects.Project{
+		{Name: "a", Path: "./projects/a", RepoURL: "git@github.com:org/a.git", Topics: []string{"web", "api", "web"},
+			Metadata: map[string]any{"owner": map[string]any{"team": "core"}, "languages": []any{"go", "ts"}}},
+		{Name: "b", Path: "./projects/b", Skip: true},
+	}}
+	if err := LoadProjects(db, pj); err != nil {
+		t.Fatal(err)
+	}
+	// Loading again replaces rather than duplicates
+	if err := LoadProjects(db, pj); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []outputs.Row
+	}{
+		{"SELECT path, name, skip FROM projects ORDER BY path", []outputs.Row{{"./projects/a", "a", int64(0)}, {"./projects/b", "b", int64(1)}}},
+		{"SELECT project_path, topic FROM project_topics ORDER BY topic", []outputs.Row{{"./projects/a", "api"}, {"./projects/a", "web"}}},
+		{"SELECT key, value FROM project_metadata ORDER BY key", []outputs.Row{{"languages", `["go","ts"]`}, {"owner.team", "core"}}},
+		{"SELECT json_extract(metadata, '$.owner.team') FROM projects WHERE path = './projects/a'", []outputs.Row{{"core"}}},
+	}
+	for _, tt := range tests {
+		summary, err := Query(db, tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(summary.Rows, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, summary.Rows)
+		}
+	}
+}
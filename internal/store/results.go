@@ -0,0 +1,313 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+// Modes for WriteResults
+const (
+	// ModeAppend adds a run and its rows, keeping earlier runs.
+	ModeAppend = "append"
+	// ModeReplace drops the script's earlier runs and results first.
+	ModeReplace = "replace"
+	// ModeUpsert replaces the rows of projects in this run, keeping the rows of
+	// projects it didn't run against. Runs left without rows are deleted.
+	ModeUpsert = "upsert"
+)
+
+// Modes lists the supported WriteResults modes.
+var Modes = []string{ModeAppend, ModeReplace, ModeUpsert}
+
+const runsSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	script TEXT NOT NULL,
+	hash TEXT,
+	args JSON,
+	ref TEXT,
+	started TEXT NOT NULL,
+	duration REAL
+);
+CREATE INDEX IF NOT EXISTS runs_script ON runs(script, started);
+`
+
+// resultColumns are written for every row ahead of the script's own columns.
+var resultColumns = []string{"run_id", "project_path", "package", "ref", "status"}
+
+// ResultsTable names the table a script's results are written to, e.g.
+// deps_results for scripts/deps.ts.
+func ResultsTable(scriptPath string) string {
+	return outputs.ScriptName(scriptPath) + "_results"
+}
+
+// WriteResults records a run in the runs table and writes each result row to
+// the script's results table, creating or widening it as needed. Column types
+// are inferred from the values: INTEGER, REAL, JSON for objects and arrays, and
+// TEXT otherwise. Strings only count as numbers when they read back the same,
+// so versions such as 1.20 or codes such as 007 stay TEXT. Results without
+// rows still get a row for their status.
+func WriteResults(db *sql.DB, info outputs.ScriptInfo, results []outputs.Result, meta outputs.RunMetadata, mode string) (int64, error) {
+	if !slices.Contains(Modes, mode) {
+		return 0, fmt.Errorf("unsupported mode %q, expected one of %s", mode, strings.Join(Modes, ", "))
+	}
+	table := ResultsTable(info.Path)
+	columns := dataColumns(info, results)
+	types := inferTypes(columns, results)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(runsSchema); err != nil {
+		return 0, fmt.Errorf("create runs table: %w", err)
+	}
+	if mode == ModeReplace {
+		if _, err := tx.Exec("DROP TABLE IF EXISTS " + QuoteIdent(table)); err != nil {
+			return 0, fmt.Errorf("drop %s: %w", table, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM runs WHERE script = ?`, meta.Script); err != nil {
+			return 0, fmt.Errorf("delete runs of %s: %w", meta.Script, err)
+		}
+	}
+	if err := ensureResultsTable(tx, table, columns, types); err != nil {
+		return 0, err
+	}
+
+	args, _ := json.Marshal(meta.Args)
+	duration, _ := time.ParseDuration(meta.Duration)
+	res, err := tx.Exec(`INSERT INTO runs (script, hash, args, ref, started, duration) VALUES (?, ?, ?, ?, ?, ?)`,
+		meta.Script, meta.LockHash, string(args), meta.Ref, meta.Started.UTC().Format(time.RFC3339), duration.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("insert run: %w", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if mode == ModeUpsert {
+		deleteStmt, err := tx.Prepare(fmt.Sprintf(`DELETE FROM %s WHERE project_path = ? AND package = ? AND ref = ?`, QuoteIdent(table)))
+		if err != nil {
+			return 0, fmt.Errorf("prepare upsert: %w", err)
+		}
+		defer deleteStmt.Close()
+		for _, r := range results {
+			if _, err := deleteStmt.Exec(r.ProjectPath, r.Package, r.Ref); err != nil {
+				return 0, fmt.Errorf("upsert %s: %w", r.Label(), err)
+			}
+		}
+		// Earlier runs whose rows were all replaced would read as empty runs
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM runs WHERE script = ? AND id != ? AND id NOT IN (SELECT run_id FROM %s)`, QuoteIdent(table)),
+			meta.Script, runID); err != nil {
+			return 0, fmt.Errorf("delete replaced runs of %s: %w", meta.Script, err)
+		}
+	}
+
+	allColumns := append(slices.Clone(resultColumns), columns...)
+	quoted := make([]string, len(allColumns))
+	for i, c := range allColumns {
+		quoted[i] = QuoteIdent(c)
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		QuoteIdent(table), strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(allColumns)), ", ")))
+	if err != nil {
+		return 0, fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range results {
+		rows := r.Rows
+		if len(rows) == 0 {
+			rows = []outputs.Row{nil}
+		}
+		for _, row := range rows {
+			values := []any{runID, r.ProjectPath, r.Package, r.Ref, r.Status}
+			for i := range columns {
+				var value any
+				if i < len(row) {
+					value = row[i]
+				}
+				values = append(values, convertValue(value, types[i]))
+			}
+			if _, err := stmt.Exec(values...); err != nil {
+				return 0, fmt.Errorf("insert %s: %w", r.Label(), err)
+			}
+		}
+	}
+
+	return runID, tx.Commit()
+}
+
+// QuoteIdent quotes a table or column name for use in SQL.
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// dataColumns names the script's columns. Scripts with json or text output
+// have a single output column.
+func dataColumns(info outputs.ScriptInfo, results []outputs.Result) []string {
+	if info.Output == "csv" && len(info.Columns) > 0 {
+		return info.Columns
+	}
+	if info.Output == "csv" {
+		// Without declared columns use the widest row
+		width := 0
+		for _, r := range results {
+			for _, row := range r.Rows {
+				width = max(width, len(row))
+			}
+		}
+		columns := make([]string, width)
+		for i := range columns {
+			columns[i] = fmt.Sprintf("column_%d", i+1)
+		}
+		return columns
+	}
+	return []string{"output"}
+}
+
+// inferTypes picks the narrowest SQLite type that holds every value of each
+// column, ignoring nulls and empty strings.
+func inferTypes(columns []string, results []outputs.Result) []string {
+	types := make([]string, len(columns))
+	for i := range columns {
+		for _, r := range results {
+			for _, row := range r.Rows {
+				if i < len(row) {
+					types[i] = widenType(types[i], valueType(row[i]))
+				}
+			}
+		}
+		if types[i] == "" {
+			types[i] = "TEXT"
+		}
+	}
+	return types
+}
+
+func valueType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool, int, int64:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "INTEGER"
+		}
+		return "REAL"
+	case string:
+		if v == "" {
+			return ""
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(n, 10) == v {
+			return "INTEGER"
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == v {
+			return "REAL"
+		}
+		return "TEXT"
+	case map[string]any, []any:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func widenType(current string, next string) string {
+	switch {
+	case next == "" || current == next:
+		return current
+	case current == "":
+		return next
+	case (current == "INTEGER" && next == "REAL") || (current == "REAL" && next == "INTEGER"):
+		return "REAL"
+	default:
+		return "TEXT"
+	}
+}
+
+// convertValue converts a row value to match its column's type.
+func convertValue(value any, columnType string) any {
+	if value == nil || (value == "" && columnType != "TEXT") {
+		return nil
+	}
+	switch columnType {
+	case "INTEGER":
+		if b, ok := value.(bool); ok {
+			if b {
+				return int64(1)
+			}
+			return int64(0)
+		}
+		n, _ := strconv.ParseInt(outputs.FormatValue(value), 10, 64)
+		return n
+	case "REAL":
+		f, _ := strconv.ParseFloat(outputs.FormatValue(value), 64)
+		return f
+	case "JSON":
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return outputs.FormatValue(value)
+	}
+}
+
+// ensureResultsTable creates a script's results table, or adds any columns it
+// is missing.
+func ensureResultsTable(tx *sql.Tx, table string, columns []string, types []string) error {
+	fixed := []string{
+		`"run_id" INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE`,
+		`"project_path" TEXT NOT NULL`,
+		`"package" TEXT NOT NULL DEFAULT ''`,
+		`"ref" TEXT NOT NULL DEFAULT ''`,
+		`"status" TEXT`,
+	}
+	if err := ensureTable(tx, table, fixed, columns, types); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (project_path, run_id)`,
+		QuoteIdent(table+"_project"), QuoteIdent(table))); err != nil {
+		return fmt.Errorf("index %s: %w", table, err)
+	}
+	return nil
+}
+
+// ensureTable creates table with the fixed column definitions followed by
+// columns, or adds any of columns it is missing. Existing columns keep their
+// type.
+func ensureTable(tx *sql.Tx, table string, fixed []string, columns []string, types []string) error {
+	defs := slices.Clone(fixed)
+	for i, c := range columns {
+		defs = append(defs, QuoteIdent(c)+" "+types[i])
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, QuoteIdent(table), strings.Join(defs, ", "))); err != nil {
+		return fmt.Errorf("create %s: %w", table, err)
+	}
+
+	existing, err := Columns(tx, table)
+	if err != nil {
+		return err
+	}
+	for i, c := range columns {
+		if containsFold(existing, c) {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, QuoteIdent(table), QuoteIdent(c), types[i])); err != nil {
+			return fmt.Errorf("add column %s to %s: %w", c, table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestWriteResults(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	info := outputs.ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "count", "ratio", "meta"}}
+	results := []outputs.Result{
+		{ProjectPath: "./projects/a", Status: "Success", Rows: []outputs.Row{
+			{"react", "3", json.Number("0.5"), map[string]any{"dev": true}},
+			{"vue, 2", "", "1", nil},
+		}},
+		{ProjectPath: "./projects/b", Status: "Error"},
+	}
+	meta := outputs.RunMetadata{Script: info.Path, Args: []string{"--all"}, Started: time.Now(), Duration: "1.5s"}
+
+	for _, mode := range []string{ModeAppend, ModeAppend, ModeUpsert} {
+		if _, err := WriteResults(db, info, results, meta, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertRows(t, db, `SELECT COUNT(*), COUNT(DISTINCT run_id) FROM deps_results`, []outputs.Row{{int64(3), int64(1)}})
+	assertRows(t, db, `SELECT COUNT(*) FROM runs`, []outputs.Row{{int64(1)}})
+	assertRows(t, db, `SELECT name, type FROM pragma_table_info('deps_results') WHERE cid >= 5 ORDER BY cid`, []outputs.Row{
+		{"name", "TEXT"}, {"count", "INTEGER"}, {"ratio", "REAL"}, {"meta", "JSON"},
+	})
+	assertRows(t, db, `SELECT project_path, status, name, count, ratio, meta FROM deps_results ORDER BY project_path, name`, []outputs.Row{
+		{"./projects/a", "Success", "react", int64(3), 0.5, `{"dev":true}`},
+		{"./projects/a", "Success", "vue, 2", nil, 1.0, nil},
+		{"./projects/b", "Error", nil, nil, nil, nil},
+	})
+
+	// Replace drops earlier runs, and new columns are added when appending
+	if _, err := WriteResults(db, info, results[:1], meta, ModeReplace); err != nil {
+		t.Fatal(err)
+	}
+	info.Columns = append(info.Columns, "license")
+	results[0].Rows = []outputs.Row{{"react", "3", "0.5", nil, "MIT"}}
+	runID, err := WriteResults(db, info, results[:1], meta, ModeAppend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT COUNT(*), MAX(args), MAX(duration) FROM runs`, []outputs.Row{{int64(2), `["--all"]`, 1.5}})
+	assertRows(t, db, `SELECT run_id, license FROM deps_results ORDER BY run_id DESC LIMIT 1`, []outputs.Row{{runID, "MIT"}})
+
+	if _, err := WriteResults(db, info, results, meta, "merge"); err == nil {
+		t.Error("Expected an error for an unsupported mode")
+	}
+}
+
+func TestWriteResults_NumericStrings(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Strings that don't read back the same as a number stay text
+	info := outputs.ScriptInfo{Path: "scripts/versions.ts", Output: "csv", Columns: []string{"version", "code", "count"}}
+	results := []outputs.Result{{ProjectPath: "./projects/a", Status: "Success", Rows: []outputs.Row{
+		{"1.20", "007", "12"},
+		{"1.3", "42", "-4"},
+	}}}
+	meta := outputs.RunMetadata{Script: info.Path, Started: time.Now()}
+	if _, err := WriteResults(db, info, results, meta, ModeAppend); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, db, `SELECT name, type FROM pragma_table_info('versions_results') WHERE cid >= 5 ORDER BY cid`, []outputs.Row{
+		{"version", "TEXT"}, {"code", "TEXT"}, {"count", "INTEGER"},
+	})
+	assertRows(t, db, `SELECT version, code, count FROM versions_results ORDER BY rowid`, []outputs.Row{
+		{"1.20", "007", int64(12)},
+		{"1.3", "42", int64(-4)},
+	})
+}
+
+func assertRows(t *testing.T, db *sql.DB, query string, want []outputs.Row) {
+	t.Helper()
+	summary, err := Query(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(summary.Rows, want) {
+		t.Errorf("%s: expected %v, got %v", query, want, summary.Rows)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const serveRunsSchema = `
+CREATE TABLE IF NOT EXISTS serve_runs (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER,
+	run JSON NOT NULL
+);
+`
+
+// SaveServeRun stores a run started through serve as JSON so its history
+// outlives the server. runID is the run its results were written under by
+// WriteResults, 0 when they weren't.
+func SaveServeRun(db *sql.DB, id int, runID int64, run []byte) error {
+	if _, err := db.Exec(serveRunsSchema); err != nil {
+		return fmt.Errorf("create serve_runs table: %w", err)
+	}
+	var resultsRun any
+	if runID != 0 {
+		resultsRun = runID
+	}
+	if _, err := db.Exec(`INSERT OR REPLACE INTO serve_runs (id, run_id, run) VALUES (?, ?, ?)`, id, resultsRun, string(run)); err != nil {
+		return fmt.Errorf("save serve run %d: %w", id, err)
+	}
+	return nil
+}
+
+// ServeRuns returns the runs saved by SaveServeRun in the order they were
+// started, none when serve hasn't saved any.
+func ServeRuns(db *sql.DB) ([][]byte, error) {
+	tables, err := Tables(db)
+	if err != nil {
+		return nil, err
+	}
+	if !containsFold(tables, "serve_runs") {
+		return nil, nil
+	}
+	rows, err := db.Query(`SELECT run FROM serve_runs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs [][]byte
+	for rows.Next() {
+		var run string
+		if err := rows.Scan(&run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, []byte(run))
+	}
+	return runs, rows.Err()
+}
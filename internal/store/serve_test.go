@@ -0,0 +1,47 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestServeRuns(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runs, err := ServeRuns(db)
+	if err != nil || runs != nil {
+		t.Fatalf("Expected no runs before any were saved, got %q, %v", runs, err)
+	}
+
+	for _, run := range []struct {
+		id    int
+		runID int64
+		data  string
+	}{
+		{2, 0, `{"id":2,"status":"failed"}`},
+		{1, 7, `{"id":1,"status":"running"}`},
+		{1, 7, `{"id":1,"status":"done"}`},
+	} {
+		if err := SaveServeRun(db, run.id, run.runID, []byte(run.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err = ServeRuns(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || string(runs[0]) != `{"id":1,"status":"done"}` || string(runs[1]) != `{"id":2,"status":"failed"}` {
+		t.Errorf("Unexpected runs %q", runs)
+	}
+
+	var runID any
+	db.QueryRow(`SELECT run_id FROM serve_runs WHERE id = 2`).Scan(&runID)
+	if runID != nil {
+		t.Errorf("Expected no results run for run 2, got %v", runID)
+	}
+}
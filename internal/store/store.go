@@ -0,0 +1,163 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/wcatron/query-projects/internal/outputs"
+	"github.com/wcatron/query-projects/internal/projects"
+)
+
+// DefaultPath is the results database written by load and read by query.
+var DefaultPath = filepath.Join(projects.ResultsFolder, "results.db")
+
+// Open opens the SQLite database at path, creating it when it doesn't exist.
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	// Force the file to be created and the connection verified
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open db %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// OpenReadOnly opens an existing database so that statements can read but
+// not change it, for running SQL that wasn't written by the user.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.ToSlash(path)+"?mode=ro&_query_only=true")
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open db %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Schema describes every table and its columns with their types, one table
+// per line, e.g. deps(Project_Path TEXT, name TEXT).
+func Schema(db *sql.DB) (string, error) {
+	tables, err := Tables(db)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, table := range tables {
+		rows, err := db.Query(`SELECT name, type FROM pragma_table_info(?) ORDER BY cid`, table)
+		if err != nil {
+			return "", err
+		}
+		var columns []string
+		for rows.Next() {
+			var name, columnType string
+			if err := rows.Scan(&name, &columnType); err != nil {
+				rows.Close()
+				return "", err
+			}
+			columns = append(columns, strings.TrimSpace(QuoteIdent(name)+" "+columnType))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s(%s)\n", QuoteIdent(table), strings.Join(columns, ", "))
+	}
+	return sb.String(), nil
+}
+
+// Query runs a SQL statement and returns its rows as a summary table, so it can
+// be printed and written like any other result. Statements that return no
+// columns give an empty summary.
+func Query(db *sql.DB, query string, args ...any) (outputs.Summary, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return outputs.Summary{}, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return outputs.Summary{}, err
+	}
+	summary := outputs.Summary{Title: query, Columns: columns}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return outputs.Summary{}, err
+		}
+		row := make(outputs.Row, len(columns))
+		for i, v := range values {
+			row[i] = normalize(v)
+		}
+		summary.Rows = append(summary.Rows, row)
+	}
+	return summary, rows.Err()
+}
+
+// normalize converts driver values into values the output writers format well.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// Querier is a *sql.DB or *sql.Tx.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Tables lists the tables in the database.
+func Tables(db Querier) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// Columns lists the columns of a table in order.
+func Columns(db Querier, table string) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
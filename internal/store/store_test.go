@@ -0,0 +1,77 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestQuery(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE deps (name TEXT, version TEXT, count INTEGER); INSERT INTO deps VALUES ('react', '18.2.0', 3), ('vue', NULL, 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := Query(db, "SELECT name, version, count FROM deps ORDER BY count DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := outputs.Summary{
+		Title:   "SELECT name, version, count FROM deps ORDER BY count DESC",
+		Columns: []string{"name", "version", "count"},
+		Rows:    []outputs.Row{{"react", "18.2.0", int64(3)}, {"vue", nil, int64(1)}},
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("Expected %+v, got %+v", want, summary)
+	}
+
+	tables, _ := Tables(db)
+	columns, _ := Columns(db, "deps")
+	if !reflect.DeepEqual(tables, []string{"deps"}) || !reflect.DeepEqual(columns, []string{"name", "version", "count"}) {
+		t.Errorf("Unexpected tables %v or columns %v", tables, columns)
+	}
+
+	completions := NewCompleter(db).Complete("SELECT na")
+	if !reflect.DeepEqual(completions, []string{"SELECT name"}) {
+		t.Errorf("Unexpected completions %v", completions)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE "it's" (name TEXT, count INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schema, err := Schema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"it's"("name" TEXT, "count" INTEGER)` + "\n"; schema != want {
+		t.Errorf("Expected schema %q, got %q", want, schema)
+	}
+	if _, err := Query(db, `DELETE FROM "it's"`); err == nil {
+		t.Error("Expected writes to fail on a read-only database")
+	}
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected an error for a missing database")
+	}
+}
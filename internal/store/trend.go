@@ -0,0 +1,116 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/analysis"
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+// TrendRuns reads the latest limit runs of a script written by run --output
+// sqlite, oldest first, with the values of column for each project. script may
+// be a path or a name such as deps. Without a column the script's first column
+// is used. where is an optional SQL condition on the results table; projects
+// without a matching row are kept with no values, so they don't look gone.
+// Only runs with the same args are compared, args of nil picks the args of the
+// script's latest run.
+func TrendRuns(db *sql.DB, script string, column string, where string, args []string, limit int) ([]analysis.TrendRun, error) {
+	table := ResultsTable(script)
+	columns, err := Columns(db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no results for %s in the database, run it with --output sqlite first", script)
+	}
+	dataColumns := columns[len(resultColumns):]
+	if column == "" && len(dataColumns) > 0 {
+		column = dataColumns[0]
+	}
+	if !containsFold(columns, column) {
+		return nil, fmt.Errorf("%s has no column %q, expected one of %s", table, column, strings.Join(dataColumns, ", "))
+	}
+
+	runs, err := scriptRuns(db, script, args, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	value := QuoteIdent(column)
+	if where != "" {
+		value = fmt.Sprintf("CASE WHEN (%s) THEN %s END", where, value)
+	}
+	query := fmt.Sprintf(`SELECT project_path, package, %s FROM %s WHERE run_id = ?`, value, QuoteIdent(table))
+	for i := range runs {
+		rows, err := db.Query(query, runs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var path, pkg string
+			var v any
+			if err := rows.Scan(&path, &pkg, &v); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			label := outputs.Result{ProjectPath: path, Package: pkg}.Label()
+			if _, ok := runs[i].Values[label]; !ok {
+				runs[i].Values[label] = []string{}
+			}
+			if v != nil {
+				runs[i].Values[label] = append(runs[i].Values[label], outputs.FormatValue(normalize(v)))
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return runs, nil
+}
+
+// scriptRuns returns the latest limit runs of script with args, oldest first.
+// With args of nil the args of the latest run are used.
+func scriptRuns(db *sql.DB, script string, args []string, limit int) ([]analysis.TrendRun, error) {
+	rows, err := db.Query(`SELECT id, script, args, started FROM runs ORDER BY started DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	name := outputs.ScriptName(script)
+	var runs []analysis.TrendRun
+	for rows.Next() && (limit <= 0 || len(runs) < limit) {
+		var id int64
+		var scriptPath, argsJSON, started string
+		if err := rows.Scan(&id, &scriptPath, &argsJSON, &started); err != nil {
+			return nil, err
+		}
+		if outputs.ScriptName(scriptPath) != name {
+			continue
+		}
+		var runArgs []string
+		_ = json.Unmarshal([]byte(argsJSON), &runArgs)
+		if args == nil {
+			args = runArgs
+			if args == nil {
+				args = []string{}
+			}
+		}
+		if !slices.Equal(runArgs, args) {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, started)
+		runs = append(runs, analysis.TrendRun{ID: id, Started: t, Args: runArgs, Values: map[string][]string{}})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(runs)
+	return runs, nil
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wcatron/query-projects/internal/outputs"
+)
+
+func TestTrendRuns(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	info := outputs.ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	started := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, version := range []string{"4.9.5", "5.1.0", "5.4.0"} {
+		results := []outputs.Result{
+			{ProjectPath: "./projects/a", Status: "Success", Rows: []outputs.Row{{"typescript", version}, {"react", "18.2.0"}}},
+			{ProjectPath: "./projects/b", Status: "Success", Rows: []outputs.Row{{"react", "18.3.1"}}},
+		}
+		meta := outputs.RunMetadata{Script: info.Path, Started: started.AddDate(0, 0, 7*i), Duration: "1s"}
+		if _, err := WriteResults(db, info, results, meta, ModeAppend); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := TrendRuns(db, "deps", "version", "name = 'typescript'", nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != 2 || runs[1].ID != 3 {
+		t.Fatalf("Expected runs 2 and 3, got %+v", runs)
+	}
+	want := map[string][]string{"./projects/a": {"5.4.0"}, "./projects/b": {}}
+	if !reflect.DeepEqual(runs[1].Values, want) {
+		t.Errorf("Expected %v, got %v", want, runs[1].Values)
+	}
+
+	if _, err := TrendRuns(db, "deps", "license", "", nil, 0); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+	if _, err := TrendRuns(db, "lint", "", "", nil, 0); err == nil {
+		t.Error("Expected an error for a script without results")
+	}
+}
+
+func TestTrendRuns_Args(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	info := outputs.ScriptInfo{Path: "scripts/deps.ts", Output: "csv", Columns: []string{"name", "version"}}
+	started := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, pkg := range []string{"react", "vue", "react", "vue"} {
+		results := []outputs.Result{{ProjectPath: "./projects/a", Status: "Success", Rows: []outputs.Row{{pkg, "1.0.0"}}}}
+		meta := outputs.RunMetadata{Script: info.Path, Args: []string{"--package", pkg}, Started: started.AddDate(0, 0, 7*i), Duration: "1s"}
+		if _, err := WriteResults(db, info, results, meta, ModeAppend); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := TrendRuns(db, "deps", "", "", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != 2 || runs[1].ID != 4 {
+		t.Fatalf("Expected the vue runs 2 and 4, got %+v", runs)
+	}
+
+	runs, err = TrendRuns(db, "deps", "", "", []string{"--package", "react"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != 1 || runs[1].ID != 3 {
+		t.Fatalf("Expected the react runs 1 and 3, got %+v", runs)
+	}
+
+	runs, err = TrendRuns(db, "deps", "", "", []string{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("Expected no runs without args, got %+v", runs)
+	}
+}
@@ -1,6 +1,12 @@
 interface ScriptConfig {
   type: 'csv' | 'json' | 'text';
   columns?: string[];
+  // How csv rows are written: RFC 4180 CSV (default) or one JSON array per line
+  encoding?: 'csv' | 'jsonl';
+  // Expectations every project must meet, e.g. 'output == true' (see run --expect)
+  expect?: string[];
+  // text/template file, relative to the root directory, to render results with
+  template?: string;
 }
 
 type ScriptReturn<T extends ScriptConfig['type']> = 
@@ -77,32 +83,124 @@ export class PackageManager {
 }
 export const packageManager: PackageManager = PackageManager.getInstance();
 
+function csvField(value: unknown): string {
+  const field = value === null || value === undefined ? '' : String(value);
+  // Quote fields containing a delimiter, quote or line break, doubling any quotes (RFC 4180)
+  return /[",\r\n]/.test(field) ? `"${field.replaceAll('"', '""')}"` : field;
+}
+
+// csvRow encodes values as a single RFC 4180 CSV record
+export function csvRow(values: unknown[]): string {
+  return values.map(csvField).join(',');
+}
+
+// Records are NDJSON messages sent to query-projects on the file descriptor in
+// QUERY_PROJECTS_RECORDS_FD, or appended to the file in
+// QUERY_PROJECTS_RECORDS_FILE on Windows. Rows sent this way keep their types
+// instead of being parsed back out of stdout.
+interface ScriptRecord {
+  type: 'row' | 'finding' | 'warning' | 'progress';
+  data?: unknown;
+  message?: string;
+}
+
+let recordsFile: Deno.FsFile | null | undefined = undefined;
+
+function recordsChannel(): Deno.FsFile | null {
+  if (recordsFile === undefined) {
+    try {
+      const fd = Deno.env.get('QUERY_PROJECTS_RECORDS_FD');
+      const file = Deno.env.get('QUERY_PROJECTS_RECORDS_FILE');
+      if (fd) {
+        recordsFile = Deno.openSync(`/dev/fd/${fd}`, { write: true });
+      } else if (file) {
+        recordsFile = Deno.openSync(file, { append: true });
+      } else {
+        recordsFile = null;
+      }
+    } catch (_) {
+      // Not run by query-projects, or the platform has no /dev/fd
+      recordsFile = null;
+    }
+  }
+  return recordsFile;
+}
+
+function sendRecord(record: ScriptRecord): boolean {
+  const channel = recordsChannel();
+  if (!channel) {
+    return false;
+  }
+  const bytes = new TextEncoder().encode(JSON.stringify(record) + '\n');
+  let written = 0;
+  while (written < bytes.length) {
+    written += channel.writeSync(bytes.subarray(written));
+  }
+  return true;
+}
+
+// warn reports a problem that doesn't stop the script
+export function warn(message: string) {
+  if (!sendRecord({ type: 'warning', message })) {
+    console.error(`[WARN] ${message}`);
+  }
+}
+
+// Finding is a rule violation at a location in the project, written to SARIF
+// and other reports. file is relative to the directory the script runs in,
+// Deno.cwd(), which is the package directory for workspaces.
+export interface Finding {
+  ruleId?: string;
+  level?: 'error' | 'warning' | 'note';
+  message: string;
+  file?: string;
+  line?: number;
+  column?: number;
+}
+
+// finding reports a rule violation in the project
+export function finding(f: Finding) {
+  if (!sendRecord({ type: 'finding', data: f })) {
+    const location = f.file ? `${f.file}${f.line ? `:${f.line}` : ''}: ` : '';
+    console.error(`[${(f.level ?? 'warning').toUpperCase()}] ${location}${f.message}`);
+  }
+}
+
+// progress reports what a long running script is doing
+export function progress(message: string) {
+  if (!sendRecord({ type: 'progress', message })) {
+    console.error(message);
+  }
+}
+
 function emitter<T extends ScriptConfig['type']>(
   type: T,
-  console: Console
+  console: Console,
+  encoding: ScriptConfig['encoding'] = 'csv',
+  useRecords = true
 ): (row: ScriptReturn<T>) => void {
   return (row) => {
     if (type === 'text') {
-      if (typeof row === 'string' || typeof row === 'number') {
-        console.log(row);
-        return;
-      } else {
+      if (typeof row !== 'string' && typeof row !== 'number') {
         throw new Error('Row with type "text" is not a string or number');
       }
+      if (!useRecords || !sendRecord({ type: 'row', data: row })) {
+        console.log(row);
+      }
     } else if (type === 'csv') {
-      if (Array.isArray(row)) {
-        console.log(row.join(','));
-        return;
-      } else {
+      if (!Array.isArray(row)) {
         throw new Error('Row with type "csv" is not an array');
       }
+      if (!useRecords || !sendRecord({ type: 'row', data: row })) {
+        console.log(encoding === 'jsonl' ? JSON.stringify(row) : csvRow(row));
+      }
     } else if (type === 'json') {
-      if (typeof row === 'object' && row !== null) {
-        console.log(JSON.stringify(row, null, 2));
-        return;
-      } else {
+      if (typeof row !== 'object' || row === null) {
         throw new Error('Row with type "json" is not an object');
       }
+      if (!useRecords || !sendRecord({ type: 'row', data: row })) {
+        console.log(JSON.stringify(row, null, 2));
+      }
     }
   };
 }
@@ -154,12 +252,15 @@ export async function script<T extends ScriptConfig['type']>(
       version: '1.0.0',
       output: config.type,
       columns: config.columns || [],
+      encoding: config.encoding || 'csv',
+      expect: config.expect || [],
+      ...(config.template ? { template: config.template } : {}),
     }));
     Deno.exit(0);
   }
 
   try {
-    const emit = emitter(config.type, console);
+    const emit = emitter(config.type, console, config.encoding, !captureConsole);
     const result = await script(emit);
     if (result) {
       emit(result);
@@ -126,6 +126,19 @@ Deno.test("script emitter with different types", async () => {
   assertEquals(resultCSV.stdout, "row1,row2\nrow3,row4\n");
 });
 
+Deno.test("script csv emitter quotes values", async () => {
+  const resultCSV = await script({ type: "csv", columns: ["name", "note"] }, (emit) => {
+    emit(["a,b", 'say "hi"']);
+    emit(["multi\nline", "plain"]);
+  }, { captureConsole: true });
+  assertEquals(resultCSV.stdout, '"a,b","say ""hi"""\n"multi\nline",plain\n');
+
+  const resultJSONL = await script({ type: "csv", columns: ["name", "note"], encoding: "jsonl" }, (emit) => {
+    emit(["a,b", 'say "hi"']);
+  }, { captureConsole: true });
+  assertEquals(resultJSONL.stdout, '["a,b","say \\"hi\\""]\n');
+});
+
 Deno.test("script error cases", async () => {
   // Test invalid emitter type for text
   const resultText = await script({ type: 'text' }, (emit) => {